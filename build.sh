@@ -1,3 +1,7 @@
 #!/bin/bash
 GOOS=linux GOARCH=amd64 go build -o backup-tool-linux cmd/backup/main.go
 GOOS=windows GOARCH=amd64 go build -o backup-tool-windows.exe cmd/backup/main.go
+GOOS=linux GOARCH=amd64 go build -o restore-tool-linux ./cmd/restore
+GOOS=windows GOARCH=amd64 go build -o restore-tool-windows.exe ./cmd/restore
+GOOS=linux GOARCH=amd64 go build -o creds-tool-linux ./cmd/creds
+GOOS=windows GOARCH=amd64 go build -o creds-tool-windows.exe ./cmd/creds
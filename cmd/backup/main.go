@@ -1,7 +1,9 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
@@ -11,8 +13,11 @@ import (
 
 	"github.com/lucasberto/database-backup-tool/internal/config"
 	"github.com/lucasberto/database-backup-tool/internal/credentials"
+	"github.com/lucasberto/database-backup-tool/internal/database"
 	"github.com/lucasberto/database-backup-tool/internal/database/mysql"
-	"github.com/lucasberto/database-backup-tool/internal/ssh"
+	"github.com/lucasberto/database-backup-tool/internal/engines"
+	"github.com/lucasberto/database-backup-tool/internal/executor"
+	"github.com/lucasberto/database-backup-tool/internal/storage"
 	"github.com/vbauerster/mpb/v8"
 )
 
@@ -28,6 +33,10 @@ type BackupResult struct {
 
 var globalConfig *config.Config
 
+var binlogsOnly = flag.Bool("binlogs-only", false, "Only archive binary logs, skipping dumps")
+
+const binlogDirName = "binlogs"
+
 func ensureOutputDir(path string) error {
 	return os.MkdirAll(path, 0755)
 }
@@ -41,7 +50,7 @@ func cleanupOldBackups(serverDir string, retentionDays int) error {
 	}
 
 	for _, entry := range entries {
-		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".sql.gz") {
+		if !entry.IsDir() && isBackupFile(entry.Name()) {
 			info, err := entry.Info()
 			if err != nil {
 				continue
@@ -58,14 +67,104 @@ func cleanupOldBackups(serverDir string, retentionDays int) error {
 	return nil
 }
 
-func backupDatabase(client *ssh.Client, serverName, serverDir, dbName string, mysqlBackup *mysql.MySQL, progress *mpb.Progress, resultsChan chan<- BackupResult) {
+// cleanupOldBinlogs removes archived binary logs that precede the binlog
+// coordinates of every remaining dump, as no restore can use them anymore.
+// Logs are only compared with dumps recorded against the same log-bin base
+// name, and nothing is removed unless at least one dump recorded its
+// coordinates.
+func cleanupOldBinlogs(serverDir string) error {
+	binlogDir := filepath.Join(serverDir, binlogDirName)
+	if _, err := os.Stat(binlogDir); os.IsNotExist(err) {
+		return nil
+	}
+
+	entries, err := os.ReadDir(serverDir)
+	if err != nil {
+		return fmt.Errorf("failed to read directory: %v", err)
+	}
+
+	// The oldest sequence number still needed for each base name.
+	oldestNeeded := make(map[string]int)
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), storage.ManifestSuffix)
+		if entry.IsDir() || !ok {
+			continue
+		}
+
+		manifest, err := storage.ReadManifest(filepath.Join(serverDir, name))
+		if err != nil || manifest.BinlogFile == "" {
+			continue
+		}
+		base, seq, err := mysql.SplitBinlogName(manifest.BinlogFile)
+		if err != nil {
+			continue
+		}
+		if oldest, ok := oldestNeeded[base]; !ok || seq < oldest {
+			oldestNeeded[base] = seq
+		}
+	}
+	if len(oldestNeeded) == 0 {
+		return nil
+	}
+
+	binlogs, err := os.ReadDir(binlogDir)
+	if err != nil {
+		return fmt.Errorf("failed to read directory: %v", err)
+	}
+
+	for _, entry := range binlogs {
+		name, _, _ := strings.Cut(entry.Name(), ".gz")
+		if entry.IsDir() {
+			continue
+		}
+		base, seq, err := mysql.SplitBinlogName(name)
+		if err != nil {
+			continue
+		}
+		if oldest, ok := oldestNeeded[base]; !ok || seq >= oldest {
+			continue
+		}
+
+		fullPath := filepath.Join(binlogDir, entry.Name())
+		if err := os.Remove(fullPath); err != nil {
+			return fmt.Errorf("failed to remove old binlog %s: %v", fullPath, err)
+		}
+	}
+	return nil
+}
+
+// backupSuffixes lists the extensions of every file written for a backup,
+// including manifests and leftovers of interrupted runs.
+var backupSuffixes = []string{".sql.gz", ".archive.gz", ".rdb.gz", ".sqlite.gz", ".xbstream.gz", storage.ManifestSuffix, ".partial"}
+
+func isBackupFile(name string) bool {
+	for _, suffix := range backupSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func backupDatabase(host executor.Executor, server config.Server, serverDir, dbName string, engine database.Engine, progress *mpb.Progress, resultsChan chan<- BackupResult) {
+	serverName := server.Name
 	dbStartTime := time.Now()
 
-	dump, err := mysqlBackup.Dump(
-		client,
-		dbName,
-		progress,
-	)
+	timestamp := dbStartTime.Format("2006-01-02_15-04-05")
+	filename := fmt.Sprintf("%s_%s%s", config.SanitizeDirectoryName(dbName), timestamp, engine.Extension())
+	fullPath := filepath.Join(serverDir, filename)
+
+	manifest := &storage.Manifest{
+		Server:    serverName,
+		Database:  dbName,
+		Engine:    server.Database.EngineType(),
+		Mode:      config.ModeLogical,
+		StartTime: dbStartTime,
+	}
+
+	err := storeBackup(fullPath, manifest, func(w io.Writer) error {
+		return engine.Dump(host, dbName, w, progress, manifest)
+	})
 	if err != nil {
 		resultsChan <- BackupResult{
 			ServerName: serverName,
@@ -78,34 +177,207 @@ func backupDatabase(client *ssh.Client, serverName, serverDir, dbName string, my
 		return
 	}
 
-	timestamp := time.Now().Format("2006-01-02_15-04-05")
-	filename := fmt.Sprintf("%s_%s.sql.gz", dbName, timestamp)
+	resultsChan <- BackupResult{
+		ServerName: serverName,
+		Database:   dbName,
+		Success:    true,
+		StartTime:  dbStartTime,
+		EndTime:    manifest.EndTime,
+		FileSize:   manifest.Size,
+	}
+}
+
+func backupPhysical(host executor.Executor, serverName, serverDir string, mysqlBackup *mysql.MySQL, progress *mpb.Progress, resultsChan chan<- BackupResult) {
+	startTime := time.Now()
+
+	timestamp := startTime.Format("2006-01-02_15-04-05")
+	filename := fmt.Sprintf("physical_%s.xbstream.gz", timestamp)
 	fullPath := filepath.Join(serverDir, filename)
 
-	if err := os.WriteFile(fullPath, dump, 0644); err != nil {
+	manifest := &storage.Manifest{
+		Server:    serverName,
+		Engine:    "mysql",
+		Mode:      config.ModePhysical,
+		StartTime: startTime,
+	}
+
+	err := storeBackup(fullPath, manifest, func(w io.Writer) error {
+		checkpoints, err := mysqlBackup.PhysicalBackup(host, w, progress)
+		if err != nil {
+			return err
+		}
+		manifest.BackupType = checkpoints.BackupType
+		manifest.FromLSN = checkpoints.FromLSN
+		manifest.ToLSN = checkpoints.ToLSN
+		manifest.LastLSN = checkpoints.LastLSN
+		return nil
+	})
+	if err != nil {
 		resultsChan <- BackupResult{
 			ServerName: serverName,
-			Database:   dbName,
+			Database:   "physical",
 			Success:    false,
 			Error:      err,
-			StartTime:  dbStartTime,
+			StartTime:  startTime,
 			EndTime:    time.Now(),
 		}
 		return
 	}
 
-	fileInfo, _ := os.Stat(fullPath)
 	resultsChan <- BackupResult{
 		ServerName: serverName,
-		Database:   dbName,
+		Database:   "physical",
 		Success:    true,
-		StartTime:  dbStartTime,
+		StartTime:  startTime,
+		EndTime:    manifest.EndTime,
+		FileSize:   manifest.Size,
+	}
+}
+
+// storeBackup streams the output of backup into fullPath and writes its
+// manifest once the backup has completed successfully.
+func storeBackup(fullPath string, manifest *storage.Manifest, backup func(w io.Writer) error) error {
+	file, err := storage.Create(fullPath)
+	if err != nil {
+		return err
+	}
+
+	if err := backup(file); err != nil {
+		file.Abort()
+		return err
+	}
+
+	manifest.EndTime = time.Now()
+	return file.Commit(manifest)
+}
+
+func backupLogical(host executor.Executor, server config.Server, serverDir string, engine database.Engine, progress *mpb.Progress, resultsChan chan BackupResult) {
+	// Without any database named, everything the engine lists is backed up.
+	backupAll := server.Database.BackupAll || (server.Database.Name == "" && len(server.Database.Databases) == 0)
+
+	var databasesToBackup []string
+	if backupAll {
+		databases, err := engine.ListDatabases(host)
+		if err != nil {
+			resultsChan <- BackupResult{
+				ServerName: server.Name,
+				Success:    false,
+				Error:      err,
+				StartTime:  time.Now(),
+				EndTime:    time.Now(),
+			}
+			return
+		}
+		databasesToBackup = databases
+	} else if len(server.Database.Databases) > 0 {
+		databasesToBackup = server.Database.Databases
+	} else {
+		databasesToBackup = []string{server.Database.Name}
+	}
+
+	dbSemaphore := make(chan struct{}, globalConfig.MaxConcurrentDatabases)
+
+	var dbWg sync.WaitGroup
+	for _, dbName := range databasesToBackup {
+		dbWg.Add(1)
+		go func(db string) {
+			defer dbWg.Done()
+			dbSemaphore <- struct{}{}
+			defer func() { <-dbSemaphore }()
+			backupDatabase(host, server, serverDir, db, engine, progress, resultsChan)
+		}(dbName)
+	}
+
+	dbWg.Wait()
+}
+
+// archiveBinlogs copies every closed binary log that is not archived yet into
+// the binlogs directory of the server.
+func archiveBinlogs(host executor.Executor, server config.Server, serverDir string, mysqlBackup *mysql.MySQL, progress *mpb.Progress, resultsChan chan<- BackupResult) {
+	startTime := time.Now()
+	binlogDir := filepath.Join(serverDir, binlogDirName)
+
+	archived, size, err := archiveClosedBinlogs(host, server, binlogDir, mysqlBackup, progress)
+	if err != nil {
+		resultsChan <- BackupResult{
+			ServerName: server.Name,
+			Database:   "binlogs",
+			Success:    false,
+			Error:      err,
+			StartTime:  startTime,
+			EndTime:    time.Now(),
+		}
+		return
+	}
+
+	resultsChan <- BackupResult{
+		ServerName: server.Name,
+		Database:   fmt.Sprintf("binlogs (%d archived)", archived),
+		Success:    true,
+		StartTime:  startTime,
 		EndTime:    time.Now(),
-		FileSize:   fileInfo.Size(),
+		FileSize:   size,
 	}
 }
 
-func backupServer(server config.Server, mysqlBackup *mysql.MySQL, progress *mpb.Progress, resultsChan chan BackupResult) {
+func archiveClosedBinlogs(host executor.Executor, server config.Server, binlogDir string, mysqlBackup *mysql.MySQL, progress *mpb.Progress) (int, int64, error) {
+	if err := ensureOutputDir(binlogDir); err != nil {
+		return 0, 0, err
+	}
+
+	if server.Database.Binlog.Flush {
+		if err := mysqlBackup.FlushBinaryLogs(host); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	logs, err := mysqlBackup.ListBinaryLogs(host)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(logs) == 0 {
+		return 0, 0, fmt.Errorf("binary logging is not enabled")
+	}
+
+	var archived int
+	var size int64
+
+	// The last log is still being written to.
+	for _, name := range logs[:len(logs)-1] {
+		fullPath := filepath.Join(binlogDir, name+".gz")
+		if _, err := os.Stat(fullPath + storage.ManifestSuffix); err == nil {
+			continue
+		}
+
+		manifest := &storage.Manifest{
+			Server:    server.Name,
+			Database:  name,
+			Engine:    "mysql",
+			Mode:      "binlog",
+			StartTime: time.Now(),
+		}
+
+		err := storeBackup(fullPath, manifest, func(w io.Writer) error {
+			binlogRange, err := mysqlBackup.ArchiveBinlog(host, name, w, progress)
+			if err != nil {
+				return err
+			}
+			manifest.FirstEvent = &binlogRange.FirstEvent
+			manifest.LastEvent = &binlogRange.LastEvent
+			return nil
+		})
+		if err != nil {
+			return archived, size, err
+		}
+
+		archived++
+		size += manifest.Size
+	}
+
+	return archived, size, nil
+}
+
+func backupServer(server config.Server, progress *mpb.Progress, resultsChan chan BackupResult) {
 
 	serverDir := filepath.Join(server.OutputPath, config.SanitizeDirectoryName(server.Name))
 	if err := ensureOutputDir(serverDir); err != nil {
@@ -119,14 +391,7 @@ func backupServer(server config.Server, mysqlBackup *mysql.MySQL, progress *mpb.
 		return
 	}
 
-	client, err := ssh.NewClient(
-		server.Host,
-		server.Port,
-		server.User,
-		server.AuthType,
-		server.KeyPath,
-		server.Passphrase,
-	)
+	host, err := executor.Connect(server)
 	if err != nil {
 		resultsChan <- BackupResult{
 			ServerName: server.Name,
@@ -137,8 +402,25 @@ func backupServer(server config.Server, mysqlBackup *mysql.MySQL, progress *mpb.
 		}
 		return
 	}
+	defer host.Close()
+
+	if !server.Database.InContainer() {
+		backupHost(host, server, serverDir, progress, resultsChan)
+		return
+	}
+
+	if server.Database.Container != "" {
+		backupHost(executor.NewContainer(host, server.Database.Runtime(), server.Database.Container), server, serverDir, progress, resultsChan)
+		return
+	}
 
-	err = client.Connect()
+	containers, err := executor.FindContainers(host, server.Database.Runtime(), server.Database.ContainerLabel, server.Database.ContainerImage)
+	if err == nil && len(containers) == 0 {
+		err = fmt.Errorf("no running containers match the configured label or image")
+	}
+	if err == nil && len(containers) > 1 && !server.Database.BackupAll {
+		err = fmt.Errorf("%d containers match container_label, set backup_all to back up all of them", len(containers))
+	}
 	if err != nil {
 		resultsChan <- BackupResult{
 			ServerName: server.Name,
@@ -150,7 +432,32 @@ func backupServer(server config.Server, mysqlBackup *mysql.MySQL, progress *mpb.
 		return
 	}
 
-	err = mysqlBackup.CreateConfigFile(client, server.Database.User, server.Database.Password, server.Database.Port)
+	// Discovered containers get a directory of their own, as a host may run
+	// several of them.
+	for _, name := range containers {
+		containerServer := server
+		containerServer.Name = server.Name + "/" + name
+		containerDir := filepath.Join(serverDir, config.SanitizeDirectoryName(name))
+		if err := ensureOutputDir(containerDir); err != nil {
+			resultsChan <- BackupResult{
+				ServerName: containerServer.Name,
+				Success:    false,
+				Error:      err,
+				StartTime:  time.Now(),
+				EndTime:    time.Now(),
+			}
+			continue
+		}
+		backupHost(executor.NewContainer(host, server.Database.Runtime(), name), containerServer, containerDir, progress, resultsChan)
+	}
+}
+
+// backupHost backs up the databases reachable through host, which is the
+// server itself or one of its containers, into serverDir.
+func backupHost(host executor.Executor, server config.Server, serverDir string, progress *mpb.Progress, resultsChan chan BackupResult) {
+	// Each server gets its own engine so the remote config file path is
+	// never shared between concurrent backups.
+	engine, err := engines.New(server.Database)
 	if err != nil {
 		resultsChan <- BackupResult{
 			ServerName: server.Name,
@@ -161,16 +468,8 @@ func backupServer(server config.Server, mysqlBackup *mysql.MySQL, progress *mpb.
 		}
 		return
 	}
-
-	var databasesToBackup []string
-	if server.Database.BackupAll {
-		databases, err := mysqlBackup.ListDatabases(
-			client,
-			server.Database.User,
-			server.Database.Password,
-			server.Database.Port,
-		)
-		if err != nil {
+	defer func() {
+		if err := engine.Cleanup(host); err != nil {
 			resultsChan <- BackupResult{
 				ServerName: server.Name,
 				Success:    false,
@@ -178,29 +477,10 @@ func backupServer(server config.Server, mysqlBackup *mysql.MySQL, progress *mpb.
 				StartTime:  time.Now(),
 				EndTime:    time.Now(),
 			}
-			return
 		}
-		databasesToBackup = databases
-	} else {
-		databasesToBackup = []string{server.Database.Name}
-	}
-
-	dbSemaphore := make(chan struct{}, globalConfig.MaxConcurrentDatabases)
-
-	var dbWg sync.WaitGroup
-	for _, dbName := range databasesToBackup {
-		dbWg.Add(1)
-		go func(db string) {
-			defer dbWg.Done()
-			dbSemaphore <- struct{}{}
-			defer func() { <-dbSemaphore }()
-			backupDatabase(client, server.Name, serverDir, db, mysqlBackup, progress, resultsChan)
-		}(dbName)
-	}
-
-	dbWg.Wait()
+	}()
 
-	err = mysqlBackup.CleanupConfigFile(client)
+	err = engine.Prepare(host)
 	if err != nil {
 		resultsChan <- BackupResult{
 			ServerName: server.Name,
@@ -212,16 +492,35 @@ func backupServer(server config.Server, mysqlBackup *mysql.MySQL, progress *mpb.
 		return
 	}
 
-	client.Close()
+	// Physical backups and binlog archiving are MySQL only, which the
+	// config validation guarantees.
+	mysqlBackup, _ := engine.(*mysql.MySQL)
+
+	if !*binlogsOnly {
+		if server.Database.Mode == config.ModePhysical {
+			backupPhysical(host, server.Name, serverDir, mysqlBackup, progress, resultsChan)
+		} else {
+			backupLogical(host, server, serverDir, engine, progress, resultsChan)
+		}
+	}
+
+	if server.Database.Binlog.Enabled {
+		archiveBinlogs(host, server, serverDir, mysqlBackup, progress, resultsChan)
+	}
 
 	if server.RetentionDays > 0 {
 		if err := cleanupOldBackups(serverDir, server.RetentionDays); err != nil {
 			log.Printf("Warning: failed to cleanup old backups for %s: %v", server.Name, err)
 		}
+		if err := cleanupOldBinlogs(serverDir); err != nil {
+			log.Printf("Warning: failed to cleanup old binlogs for %s: %v", server.Name, err)
+		}
 	}
 }
 
 func main() {
+	flag.Parse()
+
 	cfg, err := config.LoadConfig("config.yaml")
 	if err != nil {
 		log.Fatalf("Error loading config: %v", err)
@@ -229,13 +528,11 @@ func main() {
 
 	globalConfig = cfg
 
-	credManager, err := credentials.NewManager("credentials.yaml.age", cfg.PrivateKeyPath)
+	// The encrypted file is only read when a key without a provider prefix
+	// is looked up.
+	credResolver, err := credentials.NewResolver(cfg.CredentialProviders, credentials.NewFileProvider("credentials.yaml.age", cfg.PrivateKeyPath))
 	if err != nil {
-		log.Fatalf("Error initializing credential manager: %v", err)
-	}
-
-	if err := credManager.LoadCredentials(); err != nil {
-		log.Fatalf("Error loading credentials: %v", err)
+		log.Fatalf("Error initializing credential providers: %v", err)
 	}
 
 	progress := mpb.New(
@@ -245,7 +542,6 @@ func main() {
 		mpb.WithAutoRefresh(),
 	)
 
-	mysqlBackup := mysql.New()
 	var wg sync.WaitGroup
 	serverSemaphroe := make(chan struct{}, globalConfig.MaxConcurrentServers)
 	resultsChan := make(chan BackupResult)
@@ -261,28 +557,22 @@ func main() {
 	}()
 
 	for _, server := range cfg.Servers {
-
-		serverCreds, err := credManager.GetCredential(server.CredentialsKey)
-		if err != nil {
-			log.Printf("Warning: failed to load credentials for %s: %v", server.Name, err)
+		if *binlogsOnly && !server.Database.Binlog.Enabled {
 			continue
 		}
 
-		dbCreds, err := credManager.GetCredential(server.Database.CredentialsKey)
+		serverWithCreds, err := credentials.Apply(credResolver, server)
 		if err != nil {
-			log.Printf("Warning: failed to load database credentials for %s: %v", server.Name, err)
+			log.Printf("Warning: skipping %s: %v", server.Name, err)
+			continue
 		}
 
-		serverWithCreds := server
-		serverWithCreds.Passphrase = serverCreds.Passphrase
-		serverWithCreds.Database.Password = dbCreds.Password
-
 		wg.Add(1)
 		go func(server config.Server) {
 			defer wg.Done()
 			serverSemaphroe <- struct{}{}
 			defer func() { <-serverSemaphroe }()
-			backupServer(server, mysqlBackup, progress, resultsChan)
+			backupServer(server, progress, resultsChan)
 		}(serverWithCreds)
 	}
 
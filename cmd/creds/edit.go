@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/lucasberto/database-backup-tool/internal/credentials"
+	"gopkg.in/yaml.v3"
+)
+
+// edit decrypts the credentials into a private temporary directory, opens
+// them in the editor and encrypts the result. The directory, including any
+// swap or backup files the editor left there, is overwritten and removed
+// afterwards.
+func edit(credManager *credentials.Manager) error {
+	entries, err := credManager.Entries()
+	if err != nil {
+		return err
+	}
+
+	original, err := yaml.Marshal(&credentials.Credentials{Credentials: entries})
+	if err != nil {
+		return fmt.Errorf("failed to marshal credentials: %v", err)
+	}
+
+	dir, err := os.MkdirTemp(secureTempDir(), "creds-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %v", err)
+	}
+	defer shred(dir)
+
+	path := filepath.Join(dir, "credentials.yaml")
+	if err := os.WriteFile(path, original, 0600); err != nil {
+		return fmt.Errorf("failed to write temporary file: %v", err)
+	}
+
+	for {
+		if err := runEditor(path); err != nil {
+			return err
+		}
+
+		edited, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read temporary file: %v", err)
+		}
+		if bytes.Equal(edited, original) {
+			fmt.Println("No changes")
+			return nil
+		}
+
+		var creds credentials.Credentials
+		if err := parseEdited(edited, &creds); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid credentials: %v\n", err)
+			if !confirm("Edit again?") {
+				return fmt.Errorf("changes discarded")
+			}
+			continue
+		}
+
+		if err := credManager.SaveCredentials(&creds); err != nil {
+			return err
+		}
+		fmt.Println("Credentials saved")
+		return nil
+	}
+}
+
+// parseEdited decodes and validates the edited file, so both kinds of
+// mistakes can be fixed in the editor before anything is saved.
+func parseEdited(data []byte, creds *credentials.Credentials) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(creds); err != nil {
+		return err
+	}
+	return creds.Validate()
+}
+
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	// EDITOR may carry arguments, such as "code --wait".
+	fields := strings.Fields(editor)
+	cmd := exec.Command(fields[0], append(fields[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor failed: %v", err)
+	}
+	return nil
+}
+
+// secureTempDir prefers memory-backed directories so the plaintext never
+// reaches a disk.
+func secureTempDir() string {
+	for _, dir := range []string{os.Getenv("XDG_RUNTIME_DIR"), "/dev/shm"} {
+		if dir == "" {
+			continue
+		}
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+	}
+	return os.TempDir()
+}
+
+// shred overwrites every file below dir with zeros before removing it.
+func shred(dir string) {
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			return nil
+		}
+		defer f.Close()
+		f.Write(make([]byte, info.Size()))
+		f.Sync()
+		return nil
+	})
+	os.RemoveAll(dir)
+}
+
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
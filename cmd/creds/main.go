@@ -0,0 +1,295 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/lucasberto/database-backup-tool/internal/config"
+	"github.com/lucasberto/database-backup-tool/internal/credentials"
+	"golang.org/x/term"
+	"gopkg.in/yaml.v3"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage: creds [flags] <command> [arguments]
+
+Commands:
+  list                    List the credential keys and the fields they set
+  get <key> [field]       Print an entry, or a single field of it
+  set <key> <field>       Set a field, read from a hidden prompt or from stdin
+  delete <key> [field]    Delete an entry, or a single field of it
+  edit                    Edit the decrypted file in $EDITOR
+  rotate [-force] <file>  Re-encrypt to the public keys listed in file
+  rotate -passphrase      Re-encrypt with a passphrase instead of keys
+
+Fields: %s
+
+Flags:
+`, strings.Join(credentials.Fields, ", "))
+	flag.PrintDefaults()
+}
+
+func main() {
+	credsFile := flag.String("file", "credentials.yaml.age", "Encrypted credentials file")
+	identityFile := flag.String("identity", "", "Age or SSH private key file (defaults to private_key_path in config.yaml)")
+	recipientsFile := flag.String("recipients", "", "Public keys to encrypt to (defaults to recipients_path in config.yaml)")
+	flag.Usage = usage
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	if *identityFile == "" || *recipientsFile == "" {
+		cfg, err := config.LoadConfig("config.yaml")
+		if err != nil && *identityFile == "" {
+			log.Fatalf("Error loading config: %v", err)
+		}
+		if err == nil {
+			if *identityFile == "" {
+				*identityFile = cfg.PrivateKeyPath
+			}
+			if *recipientsFile == "" {
+				*recipientsFile = cfg.RecipientsPath
+			}
+		}
+	}
+
+	credManager, err := credentials.NewManager(*credsFile, *identityFile)
+	if err != nil {
+		log.Fatalf("Error initializing credential manager: %v", err)
+	}
+
+	if *recipientsFile != "" {
+		recipients, err := credentials.LoadRecipients(*recipientsFile)
+		if err != nil {
+			log.Fatalf("Error loading recipients: %v", err)
+		}
+		credManager.SetRecipients(recipients)
+	}
+
+	// set and edit may create the file; the other commands need it.
+	_, statErr := os.Stat(*credsFile)
+	created := os.IsNotExist(statErr) && (args[0] == "set" || args[0] == "edit")
+	if created {
+		err = credManager.SaveCredentials(&credentials.Credentials{})
+	} else {
+		err = credManager.LoadCredentials()
+	}
+	if err != nil {
+		log.Fatalf("Error loading credentials: %v", err)
+	}
+
+	// Without recipients, changes are encrypted to the identity alone, which
+	// would lock out everyone else the file is encrypted to. Passphrase
+	// protected files keep their passphrase when saved.
+	if *recipientsFile == "" && !credManager.PassphraseProtected() && (args[0] == "set" || args[0] == "delete" || args[0] == "edit") {
+		if n := credManager.FileRecipients(); n > 1 {
+			log.Fatalf("Error: %s is encrypted to %d recipients, set recipients_path or -recipients so saving keeps all of them", *credsFile, n)
+		}
+		if created {
+			log.Printf("Warning: no recipients_path configured, the file will only be readable with %s", *identityFile)
+		}
+	}
+
+	switch args[0] {
+	case "list":
+		err = list(credManager)
+	case "get":
+		err = get(credManager, args[1:])
+	case "set":
+		err = set(credManager, args[1:])
+	case "delete":
+		err = remove(credManager, args[1:])
+	case "edit":
+		err = edit(credManager)
+	case "rotate":
+		err = rotate(credManager, args[1:])
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+}
+
+func list(credManager *credentials.Manager) error {
+	entries, err := credManager.Entries()
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		var set []string
+		for _, field := range credentials.Fields {
+			if value, _ := entries[key].Get(field); value != "" {
+				set = append(set, field)
+			}
+		}
+		fmt.Printf("%s\t%s\n", key, strings.Join(set, ", "))
+	}
+	return nil
+}
+
+func get(credManager *credentials.Manager, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: get <key> [field]")
+	}
+
+	cred, err := credManager.GetCredential(args[0])
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 2 {
+		value, err := cred.Get(args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Println(strings.TrimSuffix(value, "\n"))
+		return nil
+	}
+
+	data, err := yaml.Marshal(cred)
+	if err != nil {
+		return err
+	}
+	os.Stdout.Write(data)
+	return nil
+}
+
+func set(credManager *credentials.Manager, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: set <key> <field>")
+	}
+	key, field := args[0], args[1]
+
+	entries, err := credManager.Entries()
+	if err != nil {
+		return err
+	}
+	cred := entries[key]
+
+	// Check the field name before asking for the value.
+	if _, err := cred.Get(field); err != nil {
+		return err
+	}
+
+	value, err := readValue(fmt.Sprintf("%s for %s", field, key))
+	if err != nil {
+		return err
+	}
+	if value == "" {
+		return fmt.Errorf("empty value, use delete to remove a field")
+	}
+
+	if err := cred.Set(field, value); err != nil {
+		return err
+	}
+	if err := credManager.SetCredential(key, cred); err != nil {
+		return err
+	}
+	return credManager.Save()
+}
+
+func remove(credManager *credentials.Manager, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: delete <key> [field]")
+	}
+
+	if len(args) == 1 {
+		if err := credManager.DeleteCredential(args[0]); err != nil {
+			return err
+		}
+		return credManager.Save()
+	}
+
+	cred, err := credManager.GetCredential(args[0])
+	if err != nil {
+		return err
+	}
+	if err := cred.Set(args[1], ""); err != nil {
+		return err
+	}
+	if err := credManager.SetCredential(args[0], cred); err != nil {
+		return err
+	}
+	return credManager.Save()
+}
+
+func rotate(credManager *credentials.Manager, args []string) error {
+	flags := flag.NewFlagSet("rotate", flag.ContinueOnError)
+	force := flags.Bool("force", false, "Rotate even if the current identity loses access")
+	passphrase := flags.Bool("passphrase", false, "Encrypt with a passphrase instead of public keys")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *passphrase == (flags.NArg() == 1) || flags.NArg() > 1 {
+		return fmt.Errorf("usage: rotate [-force] <recipients-file> or rotate -passphrase")
+	}
+
+	var recipients []age.Recipient
+	var err error
+	if *passphrase {
+		recipients, err = credentials.PassphraseRecipient()
+	} else {
+		recipients, err = credentials.LoadRecipients(flags.Arg(0))
+	}
+	if err != nil {
+		return err
+	}
+	if err := credManager.Rotate(recipients, *force); err != nil {
+		return err
+	}
+
+	fmt.Printf("Credentials re-encrypted to %d recipients\n", len(recipients))
+	return nil
+}
+
+// readValue prompts for a value without echoing it when stdin is a terminal,
+// asking twice to catch typos. Otherwise the whole of stdin is the value,
+// which suits multi-line values such as certificates.
+func readValue(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("failed to read value: %v", err)
+		}
+		return strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r"), nil
+	}
+
+	fmt.Fprintf(os.Stderr, "%s: ", prompt)
+	first, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read value: %v", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Repeat %s: ", prompt)
+	second, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read value: %v", err)
+	}
+
+	if string(first) != string(second) {
+		return "", fmt.Errorf("values do not match")
+	}
+	return string(first), nil
+}
@@ -10,10 +10,17 @@ import (
 func main() {
 	inputFile := flag.String("in", "credentials.yaml", "Input credentials file")
 	outputFile := flag.String("out", "credentials.yaml.age", "Output encrypted file")
-	publicKeyFile := flag.String("pubkey", "public-key.txt", "Age public key file")
+	publicKeyFile := flag.String("pubkey", "public-key.txt", "File with the public keys to encrypt to, one per line")
+	usePassphrase := flag.Bool("passphrase", false, "Encrypt with a passphrase instead of public keys")
 	flag.Parse()
 
-	credManager, err := credentials.NewEncryptionManager(*outputFile, *publicKeyFile)
+	var credManager *credentials.Manager
+	var err error
+	if *usePassphrase {
+		credManager, err = credentials.NewPassphraseManager(*outputFile)
+	} else {
+		credManager, err = credentials.NewEncryptionManager(*outputFile, *publicKeyFile)
+	}
 	if err != nil {
 		log.Fatalf("Error creating encryption manager: %v", err)
 	}
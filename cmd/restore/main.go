@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lucasberto/database-backup-tool/internal/config"
+	"github.com/lucasberto/database-backup-tool/internal/credentials"
+	"github.com/lucasberto/database-backup-tool/internal/database/mysql"
+	"github.com/lucasberto/database-backup-tool/internal/engines"
+	"github.com/lucasberto/database-backup-tool/internal/executor"
+	"github.com/lucasberto/database-backup-tool/internal/storage"
+	"github.com/vbauerster/mpb/v8"
+)
+
+const timeLayout = "2006-01-02 15:04:05"
+
+func findServer(cfg *config.Config, name string) (config.Server, error) {
+	for _, server := range cfg.Servers {
+		if server.Name == name {
+			return server, nil
+		}
+	}
+	return config.Server{}, fmt.Errorf("server not found in config: %s", name)
+}
+
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+func restore(server config.Server, plan *restorePlan, dbName, targetName, container string) error {
+	conn, err := executor.Connect(server)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var host executor.Executor = conn
+	if container != "" {
+		host = executor.NewContainer(conn, server.Database.Runtime(), container)
+	}
+
+	engine, err := engines.New(server.Database)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := engine.Cleanup(host); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}()
+
+	if err := engine.Prepare(host); err != nil {
+		return err
+	}
+
+	progress := mpb.New(
+		mpb.WithWidth(30),
+		mpb.WithRefreshRate(180*time.Millisecond),
+		mpb.WithAutoRefresh(),
+	)
+	defer progress.Wait()
+
+	if err := applyFile(plan.dump, func(f *os.File) error {
+		return engine.Restore(host, dbName, targetName, f, progress)
+	}); err != nil {
+		return err
+	}
+
+	// Only MySQL plans contain binlogs, which main checks.
+	mysqlRestore, _ := engine.(*mysql.MySQL)
+
+	for i, binlog := range plan.binlogs {
+		var startPosition uint64
+		if i == 0 {
+			startPosition = plan.dump.manifest.BinlogPosition
+		}
+
+		if err := applyFile(binlog, func(f *os.File) error {
+			return mysqlRestore.ReplayBinlog(host, binlog.manifest.Database, dbName, targetName, f, startPosition, plan.target, progress)
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applyFile verifies the checksum of file before handing it to apply.
+func applyFile(file backupFile, apply func(f *os.File) error) error {
+	if err := storage.Verify(file.path, file.manifest); err != nil {
+		return err
+	}
+
+	f, err := os.Open(file.path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", file.path, err)
+	}
+	defer f.Close()
+
+	return apply(f)
+}
+
+func main() {
+	serverName := flag.String("server", "", "Name of the server, as in config.yaml")
+	dbName := flag.String("database", "", "Database to restore")
+	targetName := flag.String("target", "", "Database to restore into (defaults to -database)")
+	to := flag.String("to", "", `Restore to this point in time, e.g. "2026-10-15 14:32:00" (local time)`)
+	dryRun := flag.Bool("dry-run", false, "Show the files that would be applied and exit")
+	yes := flag.Bool("yes", false, "Do not ask for confirmation")
+	container := flag.String("container", "", "Container to restore into (defaults to the configured container)")
+	flag.Parse()
+
+	if *serverName == "" || *dbName == "" {
+		log.Fatalf("Both -server and -database are required")
+	}
+	if *targetName == "" {
+		*targetName = *dbName
+	}
+
+	var target time.Time
+	if *to != "" {
+		var err error
+		target, err = time.ParseInLocation(timeLayout, *to, time.Local)
+		if err != nil {
+			log.Fatalf("Invalid -to value, expected %q: %v", timeLayout, err)
+		}
+		if target.After(time.Now()) {
+			log.Fatalf("Target time %s is in the future", *to)
+		}
+	}
+
+	cfg, err := config.LoadConfig("config.yaml")
+	if err != nil {
+		log.Fatalf("Error loading config: %v", err)
+	}
+
+	server, err := findServer(cfg, *serverName)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if server.Database.Mode == config.ModePhysical {
+		log.Fatalf("Physical backups have to be restored manually, see the readme")
+	}
+	if !target.IsZero() && !server.Database.IsMySQL() {
+		log.Fatalf("Point-in-time restore is only supported for MySQL")
+	}
+
+	serverDir := filepath.Join(server.OutputPath, config.SanitizeDirectoryName(server.Name))
+	if server.Database.DiscoversContainers() {
+		// Backups of discovered containers are kept per container.
+		if *container == "" {
+			log.Fatalf("-container is required for servers that discover their containers")
+		}
+		serverDir = filepath.Join(serverDir, config.SanitizeDirectoryName(*container))
+	} else if *container == "" {
+		*container = server.Database.Container
+	}
+	plan, err := buildPlan(serverDir, *dbName, target)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	fmt.Printf("Restoring %s on %s into %s:\n", *dbName, server.Name, *targetName)
+	plan.Print(os.Stdout)
+
+	if *dryRun {
+		return
+	}
+	if !*yes && !confirm("Apply these files?") {
+		fmt.Println("Aborted")
+		return
+	}
+
+	// The encrypted file is only read when a key without a provider prefix
+	// is looked up.
+	credResolver, err := credentials.NewResolver(cfg.CredentialProviders, credentials.NewFileProvider("credentials.yaml.age", cfg.PrivateKeyPath))
+	if err != nil {
+		log.Fatalf("Error initializing credential providers: %v", err)
+	}
+
+	server, err = credentials.Apply(credResolver, server)
+	if err != nil {
+		log.Fatalf("Error loading credentials for %s: %v", server.Name, err)
+	}
+
+	startTime := time.Now()
+	if err := restore(server, plan, *dbName, *targetName, *container); err != nil {
+		log.Fatalf("Restore failed: %v", err)
+	}
+
+	fmt.Printf("Restore completed in %s\n", time.Since(startTime).Round(time.Second))
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lucasberto/database-backup-tool/internal/config"
+	"github.com/lucasberto/database-backup-tool/internal/database/mysql"
+	"github.com/lucasberto/database-backup-tool/internal/storage"
+)
+
+const binlogDirName = "binlogs"
+
+type backupFile struct {
+	path     string
+	manifest *storage.Manifest
+}
+
+// restorePlan lists the files that are applied, in order, to restore a
+// database. Binlogs are only set for point-in-time restores.
+type restorePlan struct {
+	dump    backupFile
+	binlogs []backupFile
+	target  time.Time
+}
+
+func buildPlan(serverDir, dbName string, target time.Time) (*restorePlan, error) {
+	before := target
+	if before.IsZero() {
+		before = time.Now()
+	}
+
+	dump, err := findDump(serverDir, dbName, before)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &restorePlan{dump: dump, target: target}
+	if target.IsZero() {
+		return plan, nil
+	}
+
+	if dump.manifest.BinlogFile == "" {
+		return nil, fmt.Errorf("dump %s has no binlog coordinates; enable binlog archiving to restore to a point in time", dump.manifest.File)
+	}
+
+	plan.binlogs, err = binlogChain(filepath.Join(serverDir, binlogDirName), dump.manifest.BinlogFile, target)
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// findDump returns the newest complete logical dump of dbName that finished
+// before the given time, so it cannot contain changes made after it.
+func findDump(serverDir, dbName string, before time.Time) (backupFile, error) {
+	entries, err := os.ReadDir(serverDir)
+	if err != nil {
+		return backupFile{}, fmt.Errorf("failed to read directory: %v", err)
+	}
+
+	var newest backupFile
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), storage.ManifestSuffix)
+		if entry.IsDir() || !ok {
+			continue
+		}
+
+		fullPath := filepath.Join(serverDir, name)
+		manifest, err := storage.ReadManifest(fullPath)
+		if err != nil {
+			continue
+		}
+		if manifest.Mode != config.ModeLogical || manifest.Database != dbName || manifest.EndTime.After(before) {
+			continue
+		}
+		if newest.manifest == nil || manifest.EndTime.After(newest.manifest.EndTime) {
+			newest = backupFile{path: fullPath, manifest: manifest}
+		}
+	}
+
+	if newest.manifest == nil {
+		return backupFile{}, fmt.Errorf("no dump of %s found that finished before %s", dbName, before.Format(timeLayout))
+	}
+	return newest, nil
+}
+
+// binlogChain returns the archived binlogs from start up to the first one
+// whose last event is at or after target. It fails if a file in the sequence
+// is missing or the archive does not reach target yet.
+func binlogChain(binlogDir, start string, target time.Time) ([]backupFile, error) {
+	entries, err := os.ReadDir(binlogDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read binlog directory: %v", err)
+	}
+
+	startBase, startSeq, err := mysql.SplitBinlogName(start)
+	if err != nil {
+		return nil, err
+	}
+
+	// Names are compared by sequence number, as mysql-bin.1000000 follows
+	// mysql-bin.999999. Logs of another base name are not part of the chain.
+	var archived []backupFile
+	sequences := map[string]int{}
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), storage.ManifestSuffix)
+		if entry.IsDir() || !ok {
+			continue
+		}
+
+		fullPath := filepath.Join(binlogDir, name)
+		manifest, err := storage.ReadManifest(fullPath)
+		if err != nil {
+			return nil, err
+		}
+		base, seq, err := mysql.SplitBinlogName(manifest.Database)
+		if err != nil || base != startBase || seq < startSeq {
+			continue
+		}
+		archived = append(archived, backupFile{path: fullPath, manifest: manifest})
+		sequences[manifest.Database] = seq
+	}
+	sort.Slice(archived, func(i, j int) bool {
+		return sequences[archived[i].manifest.Database] < sequences[archived[j].manifest.Database]
+	})
+
+	if len(archived) == 0 || archived[0].manifest.Database != start {
+		return nil, fmt.Errorf("binlog %s, where the dump ends, is not archived", start)
+	}
+
+	var chain []backupFile
+	for i, binlog := range archived {
+		if i > 0 {
+			if err := checkSequence(archived[i-1].manifest.Database, binlog.manifest.Database); err != nil {
+				return nil, err
+			}
+		}
+
+		chain = append(chain, binlog)
+		if binlog.manifest.LastEvent != nil && !binlog.manifest.LastEvent.Before(target) {
+			return chain, nil
+		}
+	}
+
+	last := chain[len(chain)-1].manifest
+	lastEvent := "unknown"
+	if last.LastEvent != nil {
+		lastEvent = last.LastEvent.Format(timeLayout)
+	}
+	return nil, fmt.Errorf("archived binlogs end at %s (last event %s), before the target time; run the backup with -binlogs-only to archive newer logs", last.Database, lastEvent)
+}
+
+// checkSequence verifies that next directly follows prev, e.g.
+// mysql-bin.000041 and mysql-bin.000042.
+func checkSequence(prev, next string) error {
+	prevBase, prevSeq, err := mysql.SplitBinlogName(prev)
+	if err != nil {
+		return err
+	}
+	nextBase, nextSeq, err := mysql.SplitBinlogName(next)
+	if err != nil {
+		return err
+	}
+
+	if prevBase != nextBase || nextSeq != prevSeq+1 {
+		return fmt.Errorf("gap in binlog sequence: %s is followed by %s", prev, next)
+	}
+	return nil
+}
+
+func (p *restorePlan) Print(w io.Writer) {
+	dump := p.dump.manifest
+	fmt.Fprintf(w, "Dump:    %s (finished %s, %.2f MB)\n",
+		dump.File,
+		dump.EndTime.Format(timeLayout),
+		float64(dump.Size)/1024/1024,
+	)
+
+	if p.target.IsZero() {
+		return
+	}
+
+	fmt.Fprintf(w, "Binlogs: replay from %s:%d until %s\n", dump.BinlogFile, dump.BinlogPosition, p.target.Format(timeLayout))
+	for _, binlog := range p.binlogs {
+		manifest := binlog.manifest
+		fmt.Fprintf(w, "  %s (%s - %s)\n",
+			manifest.File,
+			formatTime(manifest.FirstEvent),
+			formatTime(manifest.LastEvent),
+		)
+	}
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "unknown"
+	}
+	return t.Format(timeLayout)
+}
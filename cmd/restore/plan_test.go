@@ -0,0 +1,166 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lucasberto/database-backup-tool/internal/config"
+	"github.com/lucasberto/database-backup-tool/internal/storage"
+)
+
+func TestCheckSequence(t *testing.T) {
+	tests := []struct {
+		prev, next string
+		wantErr    bool
+	}{
+		{prev: "mysql-bin.000041", next: "mysql-bin.000042"},
+		{prev: "mysql-bin.000999", next: "mysql-bin.001000"},
+		{prev: "host.bin.000001", next: "host.bin.000002"},
+		{prev: "mysql-bin.000041", next: "mysql-bin.000043", wantErr: true},
+		{prev: "mysql-bin.000042", next: "mysql-bin.000042", wantErr: true},
+		{prev: "mysql-bin.000041", next: "other-bin.000042", wantErr: true},
+		{prev: "mysql-bin", next: "mysql-bin.000001", wantErr: true},
+		{prev: "mysql-bin.000041", next: "mysql-bin.abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.prev+"_"+tt.next, func(t *testing.T) {
+			err := checkSequence(tt.prev, tt.next)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBuildPlan(t *testing.T) {
+	base := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	at := func(hours int) time.Time { return base.Add(time.Duration(hours) * time.Hour) }
+
+	dump := func(name string, end int, binlog string) storage.Manifest {
+		return storage.Manifest{File: name, Database: "app", Mode: config.ModeLogical, EndTime: at(end), BinlogFile: binlog, BinlogPosition: 4}
+	}
+	binlog := func(name string, first, last int) storage.Manifest {
+		firstEvent, lastEvent := at(first), at(last)
+		return storage.Manifest{File: name, Database: name, Mode: "binlog", FirstEvent: &firstEvent, LastEvent: &lastEvent}
+	}
+
+	tests := []struct {
+		name        string
+		dumps       []storage.Manifest
+		binlogs     []storage.Manifest
+		target      time.Time
+		wantDump    string
+		wantBinlogs []string
+		wantErr     string
+	}{
+		{
+			name:     "latest dump without target",
+			dumps:    []storage.Manifest{dump("app-1.sql.gz", 1, ""), dump("app-2.sql.gz", 5, "")},
+			wantDump: "app-2.sql.gz",
+		},
+		{
+			name:        "dump before target and binlogs up to it",
+			dumps:       []storage.Manifest{dump("app-1.sql.gz", 1, "mysql-bin.000002"), dump("app-2.sql.gz", 5, "mysql-bin.000004")},
+			binlogs:     []storage.Manifest{binlog("mysql-bin.000001", 0, 1), binlog("mysql-bin.000002", 1, 2), binlog("mysql-bin.000003", 2, 4), binlog("mysql-bin.000004", 4, 6)},
+			target:      at(3),
+			wantDump:    "app-1.sql.gz",
+			wantBinlogs: []string{"mysql-bin.000002", "mysql-bin.000003"},
+		},
+		{
+			name:        "sequence past six digits",
+			dumps:       []storage.Manifest{dump("app-1.sql.gz", 1, "mysql-bin.999999")},
+			binlogs:     []storage.Manifest{binlog("mysql-bin.999998", 0, 1), binlog("mysql-bin.999999", 1, 2), binlog("mysql-bin.1000000", 2, 4)},
+			target:      at(3),
+			wantDump:    "app-1.sql.gz",
+			wantBinlogs: []string{"mysql-bin.999999", "mysql-bin.1000000"},
+		},
+		{
+			name:        "other base names are ignored",
+			dumps:       []storage.Manifest{dump("app-1.sql.gz", 1, "mysql-bin.000002")},
+			binlogs:     []storage.Manifest{binlog("mysql-bin.000002", 1, 2), binlog("mysql-bin.000003", 2, 4), binlog("old-bin.000009", 0, 9)},
+			target:      at(3),
+			wantDump:    "app-1.sql.gz",
+			wantBinlogs: []string{"mysql-bin.000002", "mysql-bin.000003"},
+		},
+		{
+			name:    "no dump before target",
+			dumps:   []storage.Manifest{dump("app-1.sql.gz", 5, "mysql-bin.000001")},
+			target:  at(3),
+			wantErr: "no dump of app found",
+		},
+		{
+			name:    "dump without coordinates",
+			dumps:   []storage.Manifest{dump("app-1.sql.gz", 1, "")},
+			target:  at(3),
+			wantErr: "has no binlog coordinates",
+		},
+		{
+			name:    "starting binlog not archived",
+			dumps:   []storage.Manifest{dump("app-1.sql.gz", 1, "mysql-bin.000002")},
+			binlogs: []storage.Manifest{binlog("mysql-bin.000003", 2, 4)},
+			target:  at(3),
+			wantErr: "mysql-bin.000002, where the dump ends, is not archived",
+		},
+		{
+			name:    "gap in the archive",
+			dumps:   []storage.Manifest{dump("app-1.sql.gz", 1, "mysql-bin.000002")},
+			binlogs: []storage.Manifest{binlog("mysql-bin.000002", 1, 2), binlog("mysql-bin.000004", 4, 6)},
+			target:  at(5),
+			wantErr: "gap in binlog sequence",
+		},
+		{
+			name:    "archive ends before target",
+			dumps:   []storage.Manifest{dump("app-1.sql.gz", 1, "mysql-bin.000002")},
+			binlogs: []storage.Manifest{binlog("mysql-bin.000002", 1, 2)},
+			target:  at(3),
+			wantErr: "before the target time",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serverDir := t.TempDir()
+			binlogDir := filepath.Join(serverDir, binlogDirName)
+			if err := os.Mkdir(binlogDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			for _, manifest := range tt.dumps {
+				writeManifest(t, serverDir, manifest)
+			}
+			for _, manifest := range tt.binlogs {
+				writeManifest(t, binlogDir, manifest)
+			}
+
+			plan, err := buildPlan(serverDir, "app", tt.target)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("buildPlan: %v", err)
+			}
+
+			if plan.dump.manifest.File != tt.wantDump {
+				t.Errorf("dump %s, want %s", plan.dump.manifest.File, tt.wantDump)
+			}
+			var binlogs []string
+			for _, binlog := range plan.binlogs {
+				binlogs = append(binlogs, binlog.manifest.File)
+			}
+			if strings.Join(binlogs, " ") != strings.Join(tt.wantBinlogs, " ") {
+				t.Errorf("binlogs %v, want %v", binlogs, tt.wantBinlogs)
+			}
+		})
+	}
+}
+
+func writeManifest(t *testing.T, dir string, manifest storage.Manifest) {
+	t.Helper()
+	if err := storage.WriteManifest(filepath.Join(dir, manifest.File), &manifest); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"strings"
 
@@ -19,23 +20,265 @@ type Server struct {
 	CredentialsKey string   `yaml:"credentials_key"`
 	Database       Database `yaml:"database"`
 	RetentionDays  int      `yaml:"retention_days"`
+	Transport      string   `yaml:"transport"`
+	Become         string   `yaml:"become"`
+	BecomePassword string   `yaml:"-"`
+	Password       string   `yaml:"-"`
+	PrivateKey     string   `yaml:"-"`
+	Certificate    string   `yaml:"-"`
+
+	// Host keys are checked against known_hosts and/or certificates signed
+	// by host_ca. With neither set, any host key is accepted.
+	KnownHosts string `yaml:"known_hosts"`
+	HostCA     string `yaml:"host_ca"`
+
+	// SSHConfig resolves host through the OpenSSH config files. ProxyJump
+	// lists jump hosts as [user@]host[:port], separated by commas.
+	SSHConfig bool   `yaml:"ssh_config"`
+	ProxyJump string `yaml:"proxy_jump"`
+
+	// Timeouts in seconds. Unset, connecting times out after 30 seconds,
+	// keepalives are sent every 30 seconds and stall detection is off.
+	ConnectTimeout    int `yaml:"connect_timeout"`
+	KeepaliveInterval int `yaml:"keepalive_interval"`
+	StallTimeout      int `yaml:"stall_timeout"`
+
+	// SessionsPerConnection caps the commands run at once over one SSH
+	// connection. More connections are opened as needed. Defaults to 10.
+	SessionsPerConnection int `yaml:"sessions_per_connection"`
+}
+
+const (
+	TransportSSH   = "ssh"
+	TransportLocal = "local"
+)
+
+// ParseBecome splits a become setting such as "sudo -u mysql" into the
+// program and the user to run commands as, which is empty for root.
+func ParseBecome(become string) (program, user string, err error) {
+	fields := strings.Fields(become)
+	switch {
+	case len(fields) == 1:
+	case len(fields) == 3 && fields[1] == "-u":
+		user = fields[2]
+	default:
+		return "", "", fmt.Errorf("invalid become setting: %q", become)
+	}
+
+	switch fields[0] {
+	case "sudo", "doas":
+	default:
+		return "", "", fmt.Errorf("unsupported become program: %s", fields[0])
+	}
+	return fields[0], user, nil
 }
 
 type Database struct {
-	Type           string `yaml:"type"`
-	Port           int    `yaml:"port"`
-	Name           string `yaml:"name"`
-	User           string `yaml:"user"`
-	Password       string `yaml:"password"`
-	CredentialsKey string `yaml:"credentials_key"`
-	BackupAll      bool   `yaml:"backup_all"`
+	Type              string                 `yaml:"type"`
+	Port              int                    `yaml:"port"`
+	Name              string                 `yaml:"name"`
+	User              string                 `yaml:"user"`
+	Password          string                 `yaml:"password"`
+	CredentialsKey    string                 `yaml:"credentials_key"`
+	TLSCredentialsKey string                 `yaml:"tls_credentials_key"`
+	BackupAll         bool                   `yaml:"backup_all"`
+	Databases         []string               `yaml:"databases"`
+	Include           []string               `yaml:"include"`
+	Exclude           []string               `yaml:"exclude"`
+	Tables            map[string]TableFilter `yaml:"tables"`
+	DumpOptions       DumpOptions            `yaml:"dump_options"`
+	Mode              string                 `yaml:"mode"`
+	Physical          PhysicalOptions        `yaml:"physical"`
+	Binlog            BinlogOptions          `yaml:"binlog"`
+	AuthDatabase      string                 `yaml:"auth_database"`
+	RDBPath           string                 `yaml:"rdb_path"`
+	Dumper            string                 `yaml:"dumper"`
+
+	Container        string `yaml:"container"`
+	ContainerLabel   string `yaml:"container_label"`
+	ContainerImage   string `yaml:"container_image"`
+	ContainerRuntime string `yaml:"container_runtime"`
+
+	Host    string `yaml:"host"`
+	Socket  string `yaml:"socket"`
+	SSLMode string `yaml:"ssl_mode"`
+	SSLCA   string `yaml:"ssl_ca"`
+	SSLCert string `yaml:"ssl_cert"`
+	SSLKey  string `yaml:"ssl_key"`
+
+	// PEM material loaded from the credentials file. It takes precedence
+	// over the paths above and is uploaded for the duration of the backup.
+	SSLCAData   string `yaml:"-"`
+	SSLCertData string `yaml:"-"`
+	SSLKeyData  string `yaml:"-"`
+}
+
+const (
+	TypeMySQL   = "mysql"
+	TypeMongoDB = "mongodb"
+	TypeRedis   = "redis"
+	TypeSQLite  = "sqlite"
+)
+
+// BinlogOptions enables archiving of closed binary logs after every run.
+// Flush closes the active log first so the archive is as recent as possible.
+type BinlogOptions struct {
+	Enabled bool `yaml:"enabled"`
+	Flush   bool `yaml:"flush"`
+}
+
+// Dumpers produce logical MySQL backups. The native dumper speaks the MySQL
+// protocol over a forwarded connection and needs no tools on the remote host.
+const (
+	DumperMysqldump = "mysqldump"
+	DumperNative    = "native"
+)
+
+const (
+	ModeLogical  = "logical"
+	ModePhysical = "physical"
+)
+
+// PhysicalOptions configures physical backups, which copy the InnoDB data
+// files of the whole instance instead of dumping SQL.
+type PhysicalOptions struct {
+	Tool     string `yaml:"tool"`
+	Parallel int    `yaml:"parallel"`
+}
+
+// EngineType returns the database type, defaulting to MySQL.
+func (d Database) EngineType() string {
+	if d.Type == "" {
+		return TypeMySQL
+	}
+	return d.Type
+}
+
+func (d Database) IsMySQL() bool {
+	return d.EngineType() == TypeMySQL
+}
+
+// ConnectHost returns the host the database is connected to from the
+// remote side, defaulting to the loopback address.
+func (d Database) ConnectHost() string {
+	if d.Host == "" {
+		return "127.0.0.1"
+	}
+	return d.Host
+}
+
+// InContainer reports whether the database runs in a container on the host,
+// either named directly or discovered by label or image.
+func (d Database) InContainer() bool {
+	return d.Container != "" || d.DiscoversContainers()
+}
+
+// DiscoversContainers reports whether the containers are found by label or
+// image instead of being named.
+func (d Database) DiscoversContainers() bool {
+	return d.Container == "" && (d.ContainerLabel != "" || d.ContainerImage != "")
+}
+
+// Runtime returns the container runtime, defaulting to docker.
+func (d Database) Runtime() string {
+	if d.ContainerRuntime == "" {
+		return "docker"
+	}
+	return d.ContainerRuntime
+}
+
+// TableFilter lists the tables of a single database that need special
+// handling. ExcludeTables are left out of the backup completely, while
+// IgnoreDataTables are backed up as schema only.
+type TableFilter struct {
+	ExcludeTables    []string `yaml:"exclude_tables"`
+	IgnoreDataTables []string `yaml:"ignore_data_tables"`
+}
+
+// DumpOptions controls the flags passed to mysqldump. Unset booleans default
+// to true, which gives consistent, complete dumps of InnoDB databases.
+type DumpOptions struct {
+	SingleTransaction *bool    `yaml:"single_transaction"`
+	Routines          *bool    `yaml:"routines"`
+	Triggers          *bool    `yaml:"triggers"`
+	Events            *bool    `yaml:"events"`
+	HexBlob           *bool    `yaml:"hex_blob"`
+	SetGTIDPurged     string   `yaml:"set_gtid_purged"`
+	ExtraArgs         []string `yaml:"extra_args"`
+}
+
+// shellMetacharacters are rejected in extra_args. The arguments are quoted
+// before they reach the remote shell, but refusing them outright catches
+// configs written with shell syntax in mind.
+const shellMetacharacters = "|&;<>()$`\\\"' \t\n*?[]#~{}!"
+
+func (o DumpOptions) Validate() error {
+	switch strings.ToUpper(o.SetGTIDPurged) {
+	case "", "ON", "OFF", "AUTO", "COMMENTED":
+	default:
+		return fmt.Errorf("invalid set_gtid_purged value: %s", o.SetGTIDPurged)
+	}
+
+	for _, arg := range o.ExtraArgs {
+		if !strings.HasPrefix(arg, "-") {
+			return fmt.Errorf("extra argument %q must be an option starting with '-'", arg)
+		}
+		if strings.ContainsAny(arg, shellMetacharacters) {
+			return fmt.Errorf("extra argument %q contains shell metacharacters", arg)
+		}
+	}
+	return nil
+}
+
+// CredentialProvider configures an external source of credentials, used by
+// credentials_key values starting with the provider's name and a colon.
+type CredentialProvider struct {
+	Type      string `yaml:"type"`
+	Prefix    string `yaml:"prefix"`
+	Path      string `yaml:"path"`
+	Command   string `yaml:"command"`
+	Address   string `yaml:"address"`
+	Mount     string `yaml:"mount"`
+	Namespace string `yaml:"namespace"`
+	TokenEnv  string `yaml:"token_env"`
+}
+
+const (
+	ProviderEnv       = "env"
+	ProviderDirectory = "directory"
+	ProviderCommand   = "command"
+	ProviderVault     = "vault"
+)
+
+func (p CredentialProvider) Validate() error {
+	switch p.Type {
+	case ProviderEnv:
+	case ProviderDirectory:
+		if p.Path == "" {
+			return fmt.Errorf("directory provider needs a path")
+		}
+	case ProviderCommand:
+		if !strings.Contains(p.Command, "{key}") {
+			return fmt.Errorf("command provider needs a command containing {key}")
+		}
+	case ProviderVault:
+		if p.Address == "" {
+			return fmt.Errorf("vault provider needs an address")
+		}
+	default:
+		return fmt.Errorf("unsupported type: %s", p.Type)
+	}
+	return nil
 }
 
 type Config struct {
 	PrivateKeyPath         string   `yaml:"private_key_path"`
+	RecipientsPath         string   `yaml:"recipients_path"`
 	Servers                []Server `yaml:"servers"`
 	MaxConcurrentServers   int      `yaml:"max_concurrent_servers"`
 	MaxConcurrentDatabases int      `yaml:"max_concurrent_databases"`
+
+	CredentialProviders map[string]CredentialProvider `yaml:"credential_providers"`
 }
 
 func LoadConfig(filename string) (*Config, error) {
@@ -50,9 +293,140 @@ func LoadConfig(filename string) (*Config, error) {
 		return nil, err
 	}
 
+	for i := range config.Servers {
+		server := &config.Servers[i]
+		if server.SSHConfig && server.Transport != TransportLocal {
+			if err := server.applySSHConfig(); err != nil {
+				return nil, fmt.Errorf("server %s: %v", server.Name, err)
+			}
+		}
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
 	return config, nil
 }
 
+func (c *Config) Validate() error {
+	for name, provider := range c.CredentialProviders {
+		if name == "" || strings.Contains(name, ":") {
+			return fmt.Errorf("invalid credential provider name: %q", name)
+		}
+		if err := provider.Validate(); err != nil {
+			return fmt.Errorf("credential provider %s: %v", name, err)
+		}
+	}
+
+	for _, server := range c.Servers {
+		if err := server.Database.DumpOptions.Validate(); err != nil {
+			return fmt.Errorf("server %s: %v", server.Name, err)
+		}
+
+		switch server.Transport {
+		case "", TransportSSH, TransportLocal:
+		default:
+			return fmt.Errorf("server %s: unsupported transport: %s", server.Name, server.Transport)
+		}
+
+		if server.Transport == TransportLocal && (server.SSHConfig || server.ProxyJump != "") {
+			return fmt.Errorf("server %s: ssh_config and proxy_jump cannot be used with the local transport", server.Name)
+		}
+
+		if server.ConnectTimeout < 0 || server.KeepaliveInterval < 0 || server.StallTimeout < 0 {
+			return fmt.Errorf("server %s: timeouts cannot be negative", server.Name)
+		}
+
+		if server.SessionsPerConnection < 0 {
+			return fmt.Errorf("server %s: sessions_per_connection cannot be negative", server.Name)
+		}
+
+		if server.ProxyJump != "" {
+			if _, err := ParseProxyJump(server.ProxyJump); err != nil {
+				return fmt.Errorf("server %s: %v", server.Name, err)
+			}
+		}
+
+		switch server.AuthType {
+		case "", "key", "password", "agent":
+		default:
+			return fmt.Errorf("server %s: unsupported auth_type: %s", server.Name, server.AuthType)
+		}
+
+		if server.Become != "" {
+			if _, _, err := ParseBecome(server.Become); err != nil {
+				return fmt.Errorf("server %s: %v", server.Name, err)
+			}
+		}
+
+		switch server.Database.Type {
+		case "", TypeMySQL, TypeMongoDB, TypeRedis, TypeSQLite:
+		default:
+			return fmt.Errorf("server %s: unsupported database type: %s", server.Name, server.Database.Type)
+		}
+
+		switch server.Database.Mode {
+		case "", ModeLogical, ModePhysical:
+		default:
+			return fmt.Errorf("server %s: invalid mode: %s", server.Name, server.Database.Mode)
+		}
+
+		if !server.Database.IsMySQL() && (server.Database.Mode == ModePhysical || server.Database.Binlog.Enabled) {
+			return fmt.Errorf("server %s: physical backups and binlog archiving are only supported for MySQL", server.Name)
+		}
+
+		switch server.Database.Dumper {
+		case "", DumperMysqldump, DumperNative:
+		default:
+			return fmt.Errorf("server %s: unsupported dumper: %s", server.Name, server.Database.Dumper)
+		}
+
+		if server.Database.Dumper != "" && !server.Database.IsMySQL() {
+			return fmt.Errorf("server %s: dumper is only supported for MySQL", server.Name)
+		}
+
+		switch strings.ToUpper(server.Database.SSLMode) {
+		case "", "DISABLED", "PREFERRED", "REQUIRED", "VERIFY_CA", "VERIFY_IDENTITY":
+		default:
+			return fmt.Errorf("server %s: invalid ssl_mode: %s", server.Name, server.Database.SSLMode)
+		}
+
+		if !server.Database.IsMySQL() && (server.Database.Host != "" || server.Database.Socket != "" || server.Database.SSLMode != "") {
+			return fmt.Errorf("server %s: host, socket and ssl options are only supported for MySQL", server.Name)
+		}
+
+		if server.Database.Host != "" && server.Database.Socket != "" {
+			return fmt.Errorf("server %s: host and socket cannot both be set", server.Name)
+		}
+
+		switch server.Database.ContainerRuntime {
+		case "", "docker", "podman":
+		default:
+			return fmt.Errorf("server %s: unsupported container runtime: %s", server.Name, server.Database.ContainerRuntime)
+		}
+
+		if server.Database.Container != "" && (server.Database.ContainerLabel != "" || server.Database.ContainerImage != "") {
+			return fmt.Errorf("server %s: container cannot be combined with container_label or container_image", server.Name)
+		}
+
+		if server.Database.ContainerImage != "" && !server.Database.BackupAll {
+			return fmt.Errorf("server %s: container_image discovery requires backup_all", server.Name)
+		}
+
+		if server.Database.InContainer() && server.Database.Dumper == DumperNative {
+			return fmt.Errorf("server %s: the native dumper cannot reach databases in containers", server.Name)
+		}
+
+		switch server.Database.Physical.Tool {
+		case "", "xtrabackup", "mariabackup":
+		default:
+			return fmt.Errorf("server %s: unsupported physical backup tool: %s", server.Name, server.Database.Physical.Tool)
+		}
+	}
+	return nil
+}
+
 func SanitizeDirectoryName(name string) string {
 	// Replace spaces and special characters with underscores
 	invalidChars := []string{" ", "/", "\\", ":", "*", "?", "\"", "<", ">", "|", "&"}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kevinburke/ssh_config"
+)
+
+// SSHHost holds the settings OpenSSH config files give for a host alias.
+// Settings the files leave out are empty.
+type SSHHost struct {
+	HostName      string
+	User          string
+	Port          int
+	IdentityFiles []string
+	KnownHosts    []string
+	ProxyJump     string
+}
+
+// LookupSSHHost resolves alias through ~/.ssh/config and /etc/ssh/ssh_config.
+// The first value found wins, as with ssh itself.
+func LookupSSHHost(alias string) (SSHHost, error) {
+	host := SSHHost{HostName: alias}
+
+	values := map[string]*string{"HostName": &host.HostName, "User": &host.User, "ProxyJump": &host.ProxyJump}
+	for key, value := range values {
+		v, err := sshConfigValue(alias, key)
+		if err != nil {
+			return host, err
+		}
+		if v != "" {
+			*value = v
+		}
+	}
+	host.HostName = strings.ReplaceAll(host.HostName, "%h", alias)
+	if strings.EqualFold(host.ProxyJump, "none") {
+		host.ProxyJump = ""
+	}
+
+	port, err := sshConfigValue(alias, "Port")
+	if err != nil {
+		return host, err
+	}
+	if port != "" {
+		if host.Port, err = strconv.Atoi(port); err != nil {
+			return host, fmt.Errorf("invalid port for %s in ssh config: %s", alias, port)
+		}
+	}
+
+	files, err := ssh_config.GetAllStrict(alias, "IdentityFile")
+	if err != nil {
+		return host, fmt.Errorf("failed to read ssh config: %v", err)
+	}
+	for _, file := range files {
+		if file != ssh_config.Default("IdentityFile") {
+			host.IdentityFiles = append(host.IdentityFiles, expandSSHPath(file, host))
+		}
+	}
+
+	knownHosts, err := sshConfigValue(alias, "UserKnownHostsFile")
+	if err != nil {
+		return host, err
+	}
+	for _, file := range strings.Fields(knownHosts) {
+		// ssh skips known hosts files that do not exist.
+		if path := expandSSHPath(file, host); fileExists(path) {
+			host.KnownHosts = append(host.KnownHosts, path)
+		}
+	}
+	return host, nil
+}
+
+// sshConfigValue returns the value of key for alias, or an empty string when
+// no file sets it and ssh would fall back to its default.
+func sshConfigValue(alias, key string) (string, error) {
+	value, err := ssh_config.GetStrict(alias, key)
+	if err != nil {
+		return "", fmt.Errorf("failed to read ssh config: %v", err)
+	}
+	if value == ssh_config.Default(key) {
+		return "", nil
+	}
+	return value, nil
+}
+
+// expandSSHPath expands ~ and the %d, %h and %r tokens of a path in an ssh
+// config file.
+func expandSSHPath(path string, host SSHHost) string {
+	home, _ := os.UserHomeDir()
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		path = filepath.Join(home, path[1:])
+	}
+	return strings.NewReplacer("%d", home, "%h", host.HostName, "%r", host.User, "%%", "%").Replace(path)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// applySSHConfig fills in the connection settings of a server with ssh_config
+// enabled. Values set in config.yaml take precedence.
+func (s *Server) applySSHConfig() error {
+	host, err := LookupSSHHost(s.Host)
+	if err != nil {
+		return err
+	}
+
+	s.Host = host.HostName
+	if s.User == "" {
+		s.User = host.User
+	}
+	if s.Port == 0 {
+		s.Port = host.Port
+	}
+	if s.Port == 0 {
+		s.Port = 22
+	}
+	if s.KeyPath == "" && len(host.IdentityFiles) > 0 {
+		s.KeyPath = host.IdentityFiles[0]
+	}
+	if s.KnownHosts == "" {
+		s.KnownHosts = strings.Join(host.KnownHosts, " ")
+	}
+	if s.ProxyJump == "" {
+		s.ProxyJump = host.ProxyJump
+	}
+
+	if s.AuthType == "" {
+		s.AuthType = "agent"
+		if s.KeyPath != "" {
+			s.AuthType = "key"
+		}
+	}
+	return nil
+}
+
+// JumpHost is one hop of a proxy_jump setting. User and Port are empty when
+// the setting leaves them out.
+type JumpHost struct {
+	User string
+	Host string
+	Port int
+}
+
+// ParseProxyJump splits a ProxyJump value such as "admin@bastion:2222,gw"
+// into its hops, in the order they are connected through.
+func ParseProxyJump(value string) ([]JumpHost, error) {
+	var hops []JumpHost
+	for _, hop := range strings.Split(value, ",") {
+		var jump JumpHost
+		hop = strings.TrimSpace(hop)
+		if i := strings.LastIndex(hop, "@"); i >= 0 {
+			jump.User, hop = hop[:i], hop[i+1:]
+		}
+
+		jump.Host = hop
+		if host, port, err := net.SplitHostPort(hop); err == nil {
+			jump.Host = host
+			if jump.Port, err = strconv.Atoi(port); err != nil {
+				return nil, fmt.Errorf("invalid port in proxy_jump: %s", hop)
+			}
+		}
+		if jump.Host == "" || strings.ContainsAny(jump.Host, " /") {
+			return nil, fmt.Errorf("invalid proxy_jump host: %q", hop)
+		}
+		hops = append(hops, jump)
+	}
+	return hops, nil
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseProxyJump(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []JumpHost
+		wantErr bool
+	}{
+		{value: "bastion", want: []JumpHost{{Host: "bastion"}}},
+		{value: "admin@bastion:2222", want: []JumpHost{{User: "admin", Host: "bastion", Port: 2222}}},
+		{value: "admin@bastion:2222,gw", want: []JumpHost{{User: "admin", Host: "bastion", Port: 2222}, {Host: "gw"}}},
+		{value: " a , b:22 ", want: []JumpHost{{Host: "a"}, {Host: "b", Port: 22}}},
+		{value: "first.last@corp@gw", want: []JumpHost{{User: "first.last@corp", Host: "gw"}}},
+		{value: "[2001:db8::1]:2200", want: []JumpHost{{Host: "2001:db8::1", Port: 2200}}},
+		{value: "root@10.0.0.1", want: []JumpHost{{User: "root", Host: "10.0.0.1"}}},
+		{value: "", wantErr: true},
+		{value: "a,,b", wantErr: true},
+		{value: "admin@", wantErr: true},
+		{value: "bastion:ssh", wantErr: true},
+		{value: "ssh://bastion", wantErr: true},
+		{value: "bad host", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseProxyJump(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
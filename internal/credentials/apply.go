@@ -0,0 +1,89 @@
+package credentials
+
+import (
+	"fmt"
+
+	"github.com/lucasberto/database-backup-tool/internal/config"
+)
+
+// Apply fills in the secrets of server from the entries its credentials keys
+// point at. It checks that every entry has the type its reference expects and
+// the fields the configuration needs.
+func Apply(provider Provider, server config.Server) (config.Server, error) {
+	if server.CredentialsKey != "" {
+		cred, err := lookup(provider, server.CredentialsKey, TypeSSH)
+		if err != nil {
+			return server, err
+		}
+
+		server.Passphrase = cred.Passphrase
+		server.Password = cred.SSHPassword
+		server.PrivateKey = cred.PrivateKey
+		server.Certificate = cred.Certificate
+		server.BecomePassword = cred.BecomePassword
+		if server.User == "" {
+			server.User = cred.User
+		}
+		// Untyped entries predate ssh_password and kept it in password.
+		if cred.Type == "" && server.Password == "" {
+			server.Password = cred.Password
+		}
+	}
+
+	// Agent authentication, or a key_path from config.yaml or ssh_config,
+	// needs no entry at all.
+	if server.Transport != config.TransportLocal {
+		if server.AuthType == "password" && server.Password == "" {
+			return server, fmt.Errorf("auth_type password needs ssh_password in credentials %q", server.CredentialsKey)
+		}
+		if server.AuthType == "key" && server.KeyPath == "" && server.PrivateKey == "" {
+			return server, fmt.Errorf("auth_type key needs key_path or private_key in credentials %q", server.CredentialsKey)
+		}
+	}
+
+	if server.Database.CredentialsKey != "" {
+		cred, err := lookup(provider, server.Database.CredentialsKey, TypeDatabase)
+		if err != nil {
+			return server, err
+		}
+
+		server.Database.Password = cred.Password
+		if server.Database.User == "" {
+			server.Database.User = cred.User
+		}
+		applyTLS(&server.Database, cred)
+	}
+
+	if server.Database.TLSCredentialsKey != "" {
+		cred, err := lookup(provider, server.Database.TLSCredentialsKey, TypeTLS)
+		if err != nil {
+			return server, err
+		}
+		applyTLS(&server.Database, cred)
+	}
+
+	return server, nil
+}
+
+func applyTLS(db *config.Database, cred ServerCredentials) {
+	if cred.SSLCA != "" {
+		db.SSLCAData = cred.SSLCA
+	}
+	if cred.SSLCert != "" {
+		db.SSLCertData = cred.SSLCert
+	}
+	if cred.SSLKey != "" {
+		db.SSLKeyData = cred.SSLKey
+	}
+}
+
+func lookup(provider Provider, key, want string) (ServerCredentials, error) {
+	cred, err := provider.GetCredential(key)
+	if err != nil {
+		return ServerCredentials{}, fmt.Errorf("failed to load credentials %s: %v", key, err)
+	}
+	if err := cred.checkType(want); err != nil {
+		return ServerCredentials{}, fmt.Errorf("credentials %s %v", key, err)
+	}
+	return cred, nil
+}
@@ -0,0 +1,159 @@
+package credentials
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/lucasberto/database-backup-tool/internal/config"
+)
+
+type mapProvider map[string]ServerCredentials
+
+func (p mapProvider) GetCredential(key string) (ServerCredentials, error) {
+	cred, ok := p[key]
+	if !ok {
+		return ServerCredentials{}, fmt.Errorf("credentials not found for key: %s", key)
+	}
+	return cred, nil
+}
+
+func TestApply(t *testing.T) {
+	provider := mapProvider{
+		"ssh":        {Type: TypeSSH, User: "deploy", SSHPassword: "sshpw", BecomePassword: "sudopw"},
+		"ssh-key":    {Type: TypeSSH, PrivateKey: "key", Passphrase: "keypw"},
+		"legacy":     {User: "root", Password: "oldpw"},
+		"db":         {Type: TypeDatabase, User: "backup", Password: "dbpw", SSLCA: "ca"},
+		"tls":        {Type: TypeTLS, SSLCert: "cert", SSLKey: "key"},
+		"empty-ssh":  {Type: TypeSSH, User: "deploy"},
+		"wrong-type": {Type: TypeDatabase, User: "backup"},
+	}
+
+	tests := []struct {
+		name    string
+		server  config.Server
+		check   func(t *testing.T, server config.Server)
+		wantErr string
+	}{
+		{
+			name:   "ssh password and become",
+			server: config.Server{CredentialsKey: "ssh", AuthType: "password"},
+			check: func(t *testing.T, s config.Server) {
+				if s.User != "deploy" || s.Password != "sshpw" || s.BecomePassword != "sudopw" {
+					t.Errorf("got user %q, password %q, become password %q", s.User, s.Password, s.BecomePassword)
+				}
+			},
+		},
+		{
+			name:   "configured user wins",
+			server: config.Server{CredentialsKey: "ssh", AuthType: "password", User: "admin"},
+			check: func(t *testing.T, s config.Server) {
+				if s.User != "admin" {
+					t.Errorf("got user %q", s.User)
+				}
+			},
+		},
+		{
+			name:   "private key",
+			server: config.Server{CredentialsKey: "ssh-key", AuthType: "key"},
+			check: func(t *testing.T, s config.Server) {
+				if s.PrivateKey != "key" || s.Passphrase != "keypw" {
+					t.Errorf("got private key %q, passphrase %q", s.PrivateKey, s.Passphrase)
+				}
+			},
+		},
+		{
+			name:   "untyped entry keeps the old password field",
+			server: config.Server{CredentialsKey: "legacy", AuthType: "password"},
+			check: func(t *testing.T, s config.Server) {
+				if s.Password != "oldpw" {
+					t.Errorf("got password %q", s.Password)
+				}
+			},
+		},
+		{
+			name: "database and tls entries",
+			server: config.Server{Transport: config.TransportLocal, Database: config.Database{
+				CredentialsKey: "db", TLSCredentialsKey: "tls",
+			}},
+			check: func(t *testing.T, s config.Server) {
+				db := s.Database
+				if db.User != "backup" || db.Password != "dbpw" {
+					t.Errorf("got database user %q, password %q", db.User, db.Password)
+				}
+				if db.SSLCAData != "ca" || db.SSLCertData != "cert" || db.SSLKeyData != "key" {
+					t.Errorf("got ssl ca %q, cert %q, key %q", db.SSLCAData, db.SSLCertData, db.SSLKeyData)
+				}
+			},
+		},
+		{
+			name:    "password auth without ssh_password",
+			server:  config.Server{CredentialsKey: "empty-ssh", AuthType: "password"},
+			wantErr: "needs ssh_password",
+		},
+		{
+			name:    "key auth without a key",
+			server:  config.Server{CredentialsKey: "empty-ssh", AuthType: "key"},
+			wantErr: "needs key_path or private_key",
+		},
+		{
+			name:    "database entry used for ssh",
+			server:  config.Server{CredentialsKey: "wrong-type", AuthType: "agent"},
+			wantErr: "is a database credential, expected ssh",
+		},
+		{
+			name:    "ssh entry used for the database",
+			server:  config.Server{Transport: config.TransportLocal, Database: config.Database{CredentialsKey: "ssh"}},
+			wantErr: "is a ssh credential, expected database",
+		},
+		{
+			name:    "missing entry",
+			server:  config.Server{CredentialsKey: "nope", AuthType: "agent"},
+			wantErr: "failed to load credentials nope",
+		},
+		{
+			name:   "local server without credentials",
+			server: config.Server{Transport: config.TransportLocal},
+		},
+		{
+			name:   "agent server without credentials",
+			server: config.Server{AuthType: "agent", SSHConfig: true, User: "deploy"},
+			check: func(t *testing.T, s config.Server) {
+				if s.User != "deploy" || s.Password != "" {
+					t.Errorf("got user %q, password %q", s.User, s.Password)
+				}
+			},
+		},
+		{
+			name:   "key_path without credentials",
+			server: config.Server{AuthType: "key", KeyPath: "/home/deploy/.ssh/id_ed25519"},
+		},
+		{
+			name:    "key auth without credentials or key_path",
+			server:  config.Server{AuthType: "key"},
+			wantErr: "needs key_path or private_key",
+		},
+		{
+			name:    "password auth without credentials",
+			server:  config.Server{AuthType: "password"},
+			wantErr: "needs ssh_password",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, err := Apply(provider, tt.server)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if tt.check != nil {
+				tt.check(t, server)
+			}
+		})
+	}
+}
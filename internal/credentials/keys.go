@@ -0,0 +1,173 @@
+package credentials
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/agessh"
+	"filippo.io/age/armor"
+	"golang.org/x/crypto/ssh"
+)
+
+// loadIdentities reads the private key used to decrypt the credentials. It
+// is either an age identity file or an OpenSSH private key. Identity files
+// encrypted with a passphrase (age -p) and passphrase-protected SSH keys ask
+// for the passphrase. The recipients matching the identities are returned as
+// well.
+func loadIdentities(keyFile string) ([]age.Identity, []age.Recipient, error) {
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read private key file: %v", err)
+	}
+
+	if isEncryptedIdentity(data) {
+		data, err = decryptIdentity(data, keyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if bytes.Contains(data, []byte("PRIVATE KEY")) {
+		return parseSSHIdentity(data)
+	}
+
+	if !bytes.Contains(data, []byte("AGE-SECRET-KEY-")) {
+		return nil, nil, fmt.Errorf("invalid private key format")
+	}
+
+	identities, err := age.ParseIdentities(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse private key: %v", err)
+	}
+
+	var recipients []age.Recipient
+	for _, identity := range identities {
+		if x25519, ok := identity.(*age.X25519Identity); ok {
+			recipients = append(recipients, x25519.Recipient())
+		}
+	}
+	return identities, recipients, nil
+}
+
+const armorHeader = "-----BEGIN AGE ENCRYPTED FILE-----"
+
+func isEncryptedIdentity(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("age-encryption.org/")) ||
+		bytes.HasPrefix(bytes.TrimSpace(data), []byte(armorHeader))
+}
+
+// decryptIdentity decrypts an identity file encrypted with a passphrase,
+// armored or not.
+func decryptIdentity(data []byte, keyFile string) ([]byte, error) {
+	passphrase, err := ReadPassphrase("Passphrase for " + keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := age.NewScryptIdentity(passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	var in io.Reader = bytes.NewReader(data)
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(armorHeader)) {
+		in = armor.NewReader(bytes.NewReader(bytes.TrimSpace(data)))
+	}
+
+	reader, err := age.Decrypt(in, identity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt private key file: %v", err)
+	}
+	return io.ReadAll(reader)
+}
+
+func parseSSHIdentity(data []byte) ([]age.Identity, []age.Recipient, error) {
+	identity, err := agessh.ParseIdentity(data)
+	if missing, ok := err.(*ssh.PassphraseMissingError); ok {
+		return parseEncryptedSSHIdentity(data, missing.PublicKey)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse SSH private key: %v", err)
+	}
+
+	signer, err := ssh.ParsePrivateKey(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse SSH private key: %v", err)
+	}
+
+	recipient, err := agessh.ParseRecipient(string(ssh.MarshalAuthorizedKey(signer.PublicKey())))
+	if err != nil {
+		return nil, nil, fmt.Errorf("unsupported SSH key: %v", err)
+	}
+	return []age.Identity{identity}, []age.Recipient{recipient}, nil
+}
+
+// parseEncryptedSSHIdentity handles SSH keys protected by a passphrase,
+// which is only asked for when the key is needed to decrypt.
+func parseEncryptedSSHIdentity(data []byte, publicKey ssh.PublicKey) ([]age.Identity, []age.Recipient, error) {
+	if publicKey == nil {
+		return nil, nil, fmt.Errorf("encrypted SSH private key without public key, convert it to the OpenSSH format")
+	}
+
+	identity, err := agessh.NewEncryptedSSHIdentity(publicKey, data, func() ([]byte, error) {
+		passphrase, err := ReadPassphrase("Passphrase for SSH key")
+		return []byte(passphrase), err
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse SSH private key: %v", err)
+	}
+
+	recipient, err := agessh.ParseRecipient(string(ssh.MarshalAuthorizedKey(publicKey)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("unsupported SSH key: %v", err)
+	}
+	return []age.Identity{identity}, []age.Recipient{recipient}, nil
+}
+
+// LoadRecipients reads a recipients file: one age public key (age1...) or
+// SSH public key (ssh-ed25519, ssh-rsa) per line. Empty lines and lines
+// starting with # are ignored.
+func LoadRecipients(path string) ([]age.Recipient, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key file: %v", err)
+	}
+
+	var recipients []age.Recipient
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		recipient, err := ParseRecipient(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		recipients = append(recipients, recipient)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read public key file: %v", err)
+	}
+
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("no public keys found in %s", path)
+	}
+	return recipients, nil
+}
+
+func ParseRecipient(key string) (age.Recipient, error) {
+	switch {
+	case strings.HasPrefix(key, "age1"):
+		return age.ParseX25519Recipient(key)
+	case strings.HasPrefix(key, "ssh-ed25519 "), strings.HasPrefix(key, "ssh-rsa "):
+		return agessh.ParseRecipient(key)
+	}
+	return nil, fmt.Errorf("invalid public key format")
+}
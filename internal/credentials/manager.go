@@ -15,63 +15,122 @@ type Credentials struct {
 	Credentials map[string]ServerCredentials `yaml:"credentials"`
 }
 
-type ServerCredentials struct {
-	Passphrase string `yaml:"passphrase,omitempty"`
-	Password   string `yaml:"password,omitempty"`
+// Validate checks every entry against the fields allowed for its type.
+func (c *Credentials) Validate() error {
+	for key, cred := range c.Credentials {
+		if err := cred.Validate(); err != nil {
+			return fmt.Errorf("credentials %s: %v", key, err)
+		}
+	}
+	return nil
 }
 
 type Manager struct {
 	credsFile   string
-	keyFile     string
-	key         string
-	isPublic    bool
+	identities  []age.Identity
+	recipients  []age.Recipient
+	passphrase  bool
+	stanzas     int
 	credentials *Credentials
 }
 
+// NewManager returns a manager that decrypts with the identity in
+// privateKeyPath. Until SetRecipients is called it encrypts to that identity
+// alone. Without a private key, only credentials files encrypted with a
+// passphrase can be read.
 func NewManager(credsFile, privateKeyPath string) (*Manager, error) {
-	privateKey, err := loadPrivateKey(privateKeyPath)
-	if err != nil {
-		return nil, err
+	var identities []age.Identity
+	var recipients []age.Recipient
+	if privateKeyPath != "" {
+		var err error
+		identities, recipients, err = loadIdentities(privateKeyPath)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return &Manager{
-		credsFile: credsFile,
-		keyFile:   privateKeyPath,
-		key:       privateKey,
-		isPublic:  false,
+		credsFile:  credsFile,
+		identities: identities,
+		recipients: recipients,
 	}, nil
 }
 
+// NewEncryptionManager returns a manager that can only encrypt, to every
+// recipient listed in publicKeyPath.
 func NewEncryptionManager(credsFile, publicKeyPath string) (*Manager, error) {
-	publicKey, err := loadPublicKey(publicKeyPath)
+	recipients, err := LoadRecipients(publicKeyPath)
 	if err != nil {
 		return nil, err
 	}
 
 	return &Manager{
-		credsFile: credsFile,
-		keyFile:   publicKeyPath,
-		key:       publicKey,
-		isPublic:  true,
+		credsFile:  credsFile,
+		recipients: recipients,
 	}, nil
 }
 
-func (m *Manager) LoadCredentials() error {
-	if m.isPublic {
-		return fmt.Errorf("cannot decrypt with public key")
+// NewPassphraseManager returns a manager that encrypts with a new
+// passphrase, which it asks for.
+func NewPassphraseManager(credsFile string) (*Manager, error) {
+	recipients, err := PassphraseRecipient()
+	if err != nil {
+		return nil, err
 	}
 
+	return &Manager{
+		credsFile:  credsFile,
+		recipients: recipients,
+	}, nil
+}
+
+// PassphraseProtected reports whether the loaded credentials file is
+// encrypted with a passphrase rather than to public keys.
+func (m *Manager) PassphraseProtected() bool {
+	return m.passphrase
+}
+
+// FileRecipients returns the number of public key recipients the loaded
+// credentials file was encrypted to.
+func (m *Manager) FileRecipients() int {
+	return m.stanzas
+}
+
+// SetRecipients replaces the recipients the credentials are encrypted to.
+func (m *Manager) SetRecipients(recipients []age.Recipient) {
+	m.recipients = recipients
+}
+
+// LoadCredentials decrypts the credentials file. Files encrypted with a
+// passphrase ask for it, and are saved with the same passphrase again.
+func (m *Manager) LoadCredentials() error {
 	encrypted, err := os.ReadFile(m.credsFile)
 	if err != nil {
 		return fmt.Errorf("failed to read encrypted file: %v", err)
 	}
 
-	identity, err := age.ParseX25519Identity(m.key)
-	if err != nil {
-		return fmt.Errorf("failed to parse private key: %v", err)
+	identities := m.identities
+	if isPassphraseEncrypted(encrypted) {
+		passphrase, err := ReadPassphrase("Passphrase for " + m.credsFile)
+		if err != nil {
+			return err
+		}
+		identity, err := age.NewScryptIdentity(passphrase)
+		if err != nil {
+			return err
+		}
+		recipient, err := age.NewScryptRecipient(passphrase)
+		if err != nil {
+			return err
+		}
+		identities = []age.Identity{identity}
+		m.recipients = []age.Recipient{recipient}
+		m.passphrase = true
+	} else if len(identities) == 0 {
+		return fmt.Errorf("cannot decrypt without a private key")
 	}
 
-	decrypted, err := decrypt(encrypted, identity)
+	decrypted, err := decrypt(encrypted, identities...)
 	if err != nil {
 		return fmt.Errorf("failed to decrypt credentials: %v", err)
 	}
@@ -82,6 +141,7 @@ func (m *Manager) LoadCredentials() error {
 	}
 
 	m.credentials = &creds
+	m.stanzas = recipientStanzas(encrypted)
 	return nil
 }
 
@@ -98,78 +158,192 @@ func (m *Manager) GetCredential(key string) (ServerCredentials, error) {
 	return cred, nil
 }
 
+// Entries returns the loaded credentials keyed by credentials_key.
+func (m *Manager) Entries() (map[string]ServerCredentials, error) {
+	if m.credentials == nil {
+		return nil, fmt.Errorf("credentials not loaded")
+	}
+	return m.credentials.Credentials, nil
+}
+
+// SetCredential adds or replaces the entry for key. Call Save to write it.
+func (m *Manager) SetCredential(key string, cred ServerCredentials) error {
+	if m.credentials == nil {
+		return fmt.Errorf("credentials not loaded")
+	}
+	if m.credentials.Credentials == nil {
+		m.credentials.Credentials = map[string]ServerCredentials{}
+	}
+	m.credentials.Credentials[key] = cred
+	return nil
+}
+
+// DeleteCredential removes the entry for key. Call Save to write it.
+func (m *Manager) DeleteCredential(key string) error {
+	if m.credentials == nil {
+		return fmt.Errorf("credentials not loaded")
+	}
+	if _, exists := m.credentials.Credentials[key]; !exists {
+		return fmt.Errorf("credential not found: %s", key)
+	}
+	delete(m.credentials.Credentials, key)
+	return nil
+}
+
+// Save encrypts the loaded credentials back to the credentials file.
+func (m *Manager) Save() error {
+	if m.credentials == nil {
+		return fmt.Errorf("credentials not loaded")
+	}
+	return m.SaveCredentials(m.credentials)
+}
+
+// SaveCredentials encrypts creds to the credentials file and makes them the
+// loaded credentials.
 func (m *Manager) SaveCredentials(creds *Credentials) error {
+	if err := creds.Validate(); err != nil {
+		return err
+	}
+
 	data, err := yaml.Marshal(creds)
 	if err != nil {
 		return fmt.Errorf("failed to marshal credentials: %v", err)
 	}
 
-	recipient, err := age.ParseX25519Recipient(m.key)
+	encrypted, err := encrypt(data, m.recipients...)
 	if err != nil {
-		return fmt.Errorf("failed to parse key: %v", err)
+		return fmt.Errorf("failed to encrypt credentials: %v", err)
 	}
 
-	encrypted, err := encrypt(data, recipient)
-	if err != nil {
-		return fmt.Errorf("failed to encrypt credentials: %v", err)
+	if err := writeFile(m.credsFile, encrypted); err != nil {
+		return err
 	}
 
-	return os.WriteFile(m.credsFile, encrypted, 0600)
+	m.credentials = creds
+	return nil
 }
 
-func encrypt(data []byte, recipient age.Recipient) ([]byte, error) {
-	var buf bytes.Buffer
-	w, err := age.Encrypt(&buf, recipient)
+// Rotate re-encrypts the loaded credentials to recipients, which then become
+// the recipients of the manager. Unless force is set, it refuses a set that
+// would lock out the identity the credentials were loaded with. Rotating to
+// a passphrase is always allowed, as whoever sets it can decrypt.
+func (m *Manager) Rotate(recipients []age.Recipient, force bool) error {
+	if m.credentials == nil {
+		return fmt.Errorf("credentials not loaded")
+	}
+	if len(recipients) == 0 {
+		return fmt.Errorf("no recipients to encrypt to")
+	}
+
+	data, err := yaml.Marshal(m.credentials)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("failed to marshal credentials: %v", err)
 	}
 
-	if _, err := w.Write(data); err != nil {
-		return nil, err
+	encrypted, err := encrypt(data, recipients...)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt credentials: %v", err)
 	}
 
-	if err := w.Close(); err != nil {
-		return nil, err
+	_, passphrase := recipients[0].(*age.ScryptRecipient)
+	if _, err := decrypt(encrypted, m.identities...); err != nil && !passphrase && !force {
+		return fmt.Errorf("the current identity could not decrypt the result, none of the new recipients matches it")
 	}
 
-	return buf.Bytes(), nil
+	if err := writeFile(m.credsFile, encrypted); err != nil {
+		return err
+	}
+
+	m.recipients = recipients
+	_, m.passphrase = recipients[0].(*age.ScryptRecipient)
+	return nil
 }
 
-func decrypt(encrypted []byte, identity age.Identity) ([]byte, error) {
-	reader, err := age.Decrypt(bytes.NewReader(encrypted), identity)
+// PassphraseRecipient asks for a new passphrase to encrypt the credentials
+// with, for use with Rotate.
+func PassphraseRecipient() ([]age.Recipient, error) {
+	passphrase, err := NewPassphrase("New passphrase")
 	if err != nil {
 		return nil, err
 	}
+	recipient, err := age.NewScryptRecipient(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return []age.Recipient{recipient}, nil
+}
 
-	return io.ReadAll(reader)
+// isPassphraseEncrypted reports whether an age file was encrypted with a
+// passphrase, whose scrypt stanza is the only one in the header.
+func isPassphraseEncrypted(encrypted []byte) bool {
+	header := encrypted
+	if i := bytes.Index(encrypted, []byte("\n---")); i >= 0 {
+		header = encrypted[:i]
+	}
+	return bytes.Contains(header, []byte("\n-> scrypt "))
 }
 
-func loadPrivateKey(keyFile string) (string, error) {
-	data, err := os.ReadFile(keyFile)
-	if err != nil {
-		return "", fmt.Errorf("failed to read private key file: %v", err)
+// recipientStanzas counts the recipient stanzas in the header of an age
+// file, leaving out passphrase and grease stanzas.
+func recipientStanzas(encrypted []byte) int {
+	header := encrypted
+	if i := bytes.Index(encrypted, []byte("\n---")); i >= 0 {
+		header = encrypted[:i]
 	}
 
-	key := strings.TrimSpace(string(data))
-	if !strings.HasPrefix(key, "AGE-SECRET-KEY-") {
-		return "", fmt.Errorf("invalid private key format")
+	count := 0
+	for _, line := range strings.Split(string(header), "\n") {
+		stanza, ok := strings.CutPrefix(line, "-> ")
+		if !ok {
+			continue
+		}
+		kind, _, _ := strings.Cut(stanza, " ")
+		if kind != "scrypt" && !strings.HasSuffix(kind, "-grease") {
+			count++
+		}
 	}
+	return count
+}
 
-	return key, nil
+// writeFile writes data to a temporary file next to path first, so a failed
+// write cannot leave a truncated credentials file behind.
+func writeFile(path string, data []byte) error {
+	tmpFile := path + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0600); err != nil {
+		return fmt.Errorf("failed to write credentials: %v", err)
+	}
+	if err := os.Rename(tmpFile, path); err != nil {
+		os.Remove(tmpFile)
+		return fmt.Errorf("failed to write credentials: %v", err)
+	}
+	return nil
 }
 
-func loadPublicKey(keyFile string) (string, error) {
-	data, err := os.ReadFile(keyFile)
+func encrypt(data []byte, recipients ...age.Recipient) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, recipients...)
 	if err != nil {
-		return "", fmt.Errorf("failed to read public key file: %v", err)
+		return nil, err
 	}
 
-	key := strings.TrimSpace(string(data))
-	if !strings.HasPrefix(key, "age1") {
-		return "", fmt.Errorf("invalid public key format")
+	if _, err := w.Write(data); err != nil {
+		return nil, err
 	}
 
-	return key, nil
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func decrypt(encrypted []byte, identities ...age.Identity) ([]byte, error) {
+	reader, err := age.Decrypt(bytes.NewReader(encrypted), identities...)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(reader)
 }
 
 func (m *Manager) EncryptFile(inputFile string) error {
@@ -183,13 +357,11 @@ func (m *Manager) EncryptFile(inputFile string) error {
 	if err := yaml.Unmarshal(data, &creds); err != nil {
 		return fmt.Errorf("invalid credentials format: %v", err)
 	}
-
-	recipient, err := age.ParseX25519Recipient(m.key)
-	if err != nil {
-		return fmt.Errorf("failed to parse public key: %v", err)
+	if err := creds.Validate(); err != nil {
+		return err
 	}
 
-	encrypted, err := encrypt(data, recipient)
+	encrypted, err := encrypt(data, m.recipients...)
 	if err != nil {
 		return fmt.Errorf("failed to encrypt data: %v", err)
 	}
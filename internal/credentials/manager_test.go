@@ -0,0 +1,43 @@
+package credentials
+
+import (
+	"testing"
+
+	"filippo.io/age"
+)
+
+func TestRecipientStanzas(t *testing.T) {
+	recipient := func() age.Recipient {
+		identity, err := age.GenerateX25519Identity()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return identity.Recipient()
+	}
+	passphrase, err := age.NewScryptRecipient("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	passphrase.SetWorkFactor(10)
+
+	tests := []struct {
+		name       string
+		recipients []age.Recipient
+		want       int
+	}{
+		{name: "one key", recipients: []age.Recipient{recipient()}, want: 1},
+		{name: "several keys", recipients: []age.Recipient{recipient(), recipient(), recipient()}, want: 3},
+		{name: "passphrase", recipients: []age.Recipient{passphrase}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encrypted, err := encrypt([]byte("credentials: {}\n"), tt.recipients...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := recipientStanzas(encrypted); got != tt.want {
+				t.Errorf("got %d stanzas, want %d", got, tt.want)
+			}
+		})
+	}
+}
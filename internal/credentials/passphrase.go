@@ -0,0 +1,118 @@
+package credentials
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/term"
+)
+
+// Passphrases are read from these environment variables before falling back
+// to a prompt on the terminal. PassphraseFDEnv names an inherited file
+// descriptor, which keeps the passphrase out of the environment. A passphrase
+// to encrypt with is only taken from NewPassphraseEnv, never from the
+// variables used to decrypt.
+const (
+	PassphraseEnv    = "BACKUP_PASSPHRASE"
+	PassphraseFDEnv  = "BACKUP_PASSPHRASE_FD"
+	NewPassphraseEnv = "BACKUP_NEW_PASSPHRASE"
+)
+
+var (
+	fdPassphrase     string
+	fdPassphraseErr  error
+	fdPassphraseOnce sync.Once
+)
+
+// ReadPassphrase returns the passphrase from the environment, the file
+// descriptor or, failing both, a prompt on the terminal.
+func ReadPassphrase(prompt string) (string, error) {
+	if passphrase, ok, err := passphraseFromEnv(); ok || err != nil {
+		return passphrase, err
+	}
+	return promptPassphrase(prompt, PassphraseEnv+" or "+PassphraseFDEnv)
+}
+
+// NewPassphrase returns the passphrase to encrypt with from NewPassphraseEnv
+// or, failing that, asks twice on the terminal so a typo cannot lock the
+// file.
+func NewPassphrase(prompt string) (string, error) {
+	if passphrase := os.Getenv(NewPassphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+
+	first, err := promptPassphrase(prompt, NewPassphraseEnv)
+	if err != nil {
+		return "", err
+	}
+	second, err := promptPassphrase("Repeat "+strings.ToLower(prompt[:1])+prompt[1:], NewPassphraseEnv)
+	if err != nil {
+		return "", err
+	}
+	if first != second {
+		return "", fmt.Errorf("passphrases do not match")
+	}
+	return first, nil
+}
+
+func passphraseFromEnv() (string, bool, error) {
+	if passphrase := os.Getenv(PassphraseEnv); passphrase != "" {
+		return passphrase, true, nil
+	}
+
+	fdValue := os.Getenv(PassphraseFDEnv)
+	if fdValue == "" {
+		return "", false, nil
+	}
+
+	// A descriptor can only be read once, so the result is kept for the
+	// identity and the credentials file alike.
+	fdPassphraseOnce.Do(func() {
+		fd, err := strconv.Atoi(fdValue)
+		if err != nil {
+			fdPassphraseErr = fmt.Errorf("invalid %s: %s", PassphraseFDEnv, fdValue)
+			return
+		}
+
+		f := os.NewFile(uintptr(fd), "passphrase")
+		if f == nil {
+			fdPassphraseErr = fmt.Errorf("invalid %s: %s", PassphraseFDEnv, fdValue)
+			return
+		}
+		defer f.Close()
+
+		line, err := bufio.NewReader(f).ReadString('\n')
+		if err != nil && line == "" {
+			fdPassphraseErr = fmt.Errorf("failed to read passphrase from descriptor %d: %v", fd, err)
+			return
+		}
+		fdPassphrase = strings.TrimRight(line, "\r\n")
+	})
+	return fdPassphrase, true, fdPassphraseErr
+}
+
+// promptPassphrase reads from the controlling terminal, so it works even
+// when stdin is redirected. The error names the variables to use instead.
+func promptPassphrase(prompt, env string) (string, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return "", fmt.Errorf("a passphrase is required, set %s when not running in a terminal", env)
+		}
+		tty = os.Stdin
+	} else {
+		defer tty.Close()
+	}
+
+	fmt.Fprintf(os.Stderr, "%s: ", prompt)
+	passphrase, err := term.ReadPassword(int(tty.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %v", err)
+	}
+	return string(passphrase), nil
+}
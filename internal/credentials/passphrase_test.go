@@ -0,0 +1,16 @@
+package credentials
+
+import "testing"
+
+func TestNewPassphraseIgnoresDecryptionSources(t *testing.T) {
+	t.Setenv(PassphraseEnv, "old")
+	t.Setenv(NewPassphraseEnv, "new")
+
+	passphrase, err := NewPassphrase("New passphrase")
+	if err != nil {
+		t.Fatalf("NewPassphrase: %v", err)
+	}
+	if passphrase != "new" {
+		t.Errorf("got %q, want the passphrase from %s", passphrase, NewPassphraseEnv)
+	}
+}
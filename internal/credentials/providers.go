@@ -0,0 +1,216 @@
+package credentials
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/lucasberto/database-backup-tool/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// Provider resolves a credentials key to an entry.
+type Provider interface {
+	GetCredential(key string) (ServerCredentials, error)
+}
+
+// Resolver looks credentials keys up in the provider named by their prefix,
+// as in "vault:prod/db", and in the age-encrypted file otherwise.
+type Resolver struct {
+	providers map[string]Provider
+	fallback  Provider
+}
+
+// NewResolver builds the providers configured in config.yaml. The built-in
+// "env" provider reads environment variables without a prefix.
+func NewResolver(providers map[string]config.CredentialProvider, fallback Provider) (*Resolver, error) {
+	r := &Resolver{
+		providers: map[string]Provider{"env": &envProvider{}},
+		fallback:  fallback,
+	}
+
+	for name, provider := range providers {
+		switch provider.Type {
+		case config.ProviderEnv:
+			r.providers[name] = &envProvider{prefix: provider.Prefix}
+		case config.ProviderDirectory:
+			r.providers[name] = &directoryProvider{path: provider.Path}
+		case config.ProviderCommand:
+			r.providers[name] = &commandProvider{command: provider.Command}
+		case config.ProviderVault:
+			r.providers[name] = newVaultProvider(provider)
+		default:
+			return nil, fmt.Errorf("unsupported credential provider type: %s", provider.Type)
+		}
+	}
+	return r, nil
+}
+
+func (r *Resolver) GetCredential(key string) (ServerCredentials, error) {
+	if name, rest, ok := strings.Cut(key, ":"); ok {
+		if provider, exists := r.providers[name]; exists {
+			cred, err := provider.GetCredential(rest)
+			if err != nil {
+				return ServerCredentials{}, fmt.Errorf("%s: %v", name, err)
+			}
+			return cred, nil
+		}
+	}
+	return r.fallback.GetCredential(key)
+}
+
+// FileProvider reads the age-encrypted credentials file. The file is only
+// decrypted when a key is first looked up, so it does not have to exist when
+// every key comes from another provider.
+type FileProvider struct {
+	credsFile      string
+	privateKeyPath string
+	once           sync.Once
+	manager        *Manager
+	err            error
+}
+
+func NewFileProvider(credsFile, privateKeyPath string) *FileProvider {
+	return &FileProvider{credsFile: credsFile, privateKeyPath: privateKeyPath}
+}
+
+func (p *FileProvider) GetCredential(key string) (ServerCredentials, error) {
+	p.once.Do(func() {
+		p.manager, p.err = NewManager(p.credsFile, p.privateKeyPath)
+		if p.err == nil {
+			p.err = p.manager.LoadCredentials()
+		}
+	})
+	if p.err != nil {
+		return ServerCredentials{}, p.err
+	}
+	return p.manager.GetCredential(key)
+}
+
+// fromFields builds an entry from values keyed by field name. Unknown
+// fields are ignored, as external secrets often carry more than we need.
+func fromFields(values map[string]string) ServerCredentials {
+	var cred ServerCredentials
+	for _, field := range Fields {
+		if value, ok := values[field]; ok {
+			cred.Set(field, value)
+		}
+	}
+	return cred
+}
+
+// envProvider reads PREFIX_KEY_FIELD variables, so "env:prod_db" reads
+// PROD_DB_PASSWORD for the password.
+type envProvider struct {
+	prefix string
+}
+
+func (p *envProvider) GetCredential(key string) (ServerCredentials, error) {
+	values := map[string]string{}
+	for _, field := range Fields {
+		if value, ok := os.LookupEnv(envName(p.prefix + key + "_" + field)); ok {
+			values[field] = value
+		}
+	}
+	if len(values) == 0 {
+		return ServerCredentials{}, fmt.Errorf("no variables set for %s", envName(p.prefix+key+"_*"))
+	}
+	return fromFields(values), nil
+}
+
+func envName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '*' || r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, name)
+}
+
+// directoryProvider reads one file per field from a directory per key, the
+// layout of mounted Kubernetes and Docker secrets.
+type directoryProvider struct {
+	path string
+}
+
+func (p *directoryProvider) GetCredential(key string) (ServerCredentials, error) {
+	dir := filepath.Join(p.path, filepath.FromSlash(key))
+	if !strings.HasPrefix(dir, filepath.Clean(p.path)+string(filepath.Separator)) {
+		return ServerCredentials{}, fmt.Errorf("invalid key: %s", key)
+	}
+
+	values := map[string]string{}
+	for _, field := range Fields {
+		data, err := os.ReadFile(filepath.Join(dir, field))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return ServerCredentials{}, err
+		}
+		values[field] = strings.TrimRight(string(data), "\r\n")
+	}
+	if len(values) == 0 {
+		return ServerCredentials{}, fmt.Errorf("no secrets found in %s", dir)
+	}
+	return fromFields(values), nil
+}
+
+// commandProvider runs a command with {key} and, optionally, {field}
+// replaced. With {field} it runs once per field and a failing command means
+// the field is not set, unless it fails for every field. Without it, the
+// command prints the whole entry as YAML.
+type commandProvider struct {
+	command string
+}
+
+func (p *commandProvider) GetCredential(key string) (ServerCredentials, error) {
+	if !strings.Contains(p.command, "{field}") {
+		output, err := p.run(key, "")
+		if err != nil {
+			return ServerCredentials{}, err
+		}
+		var cred ServerCredentials
+		if err := yaml.Unmarshal(output, &cred); err != nil {
+			return ServerCredentials{}, fmt.Errorf("invalid command output: %v", err)
+		}
+		return cred, nil
+	}
+
+	values := map[string]string{}
+	var failures []string
+	for _, field := range Fields {
+		output, err := p.run(key, field)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", field, err))
+			continue
+		}
+		values[field] = strings.TrimRight(string(output), "\r\n")
+	}
+	if len(values) == 0 {
+		return ServerCredentials{}, fmt.Errorf("command returned no secrets for %s: %s", key, strings.Join(failures, "; "))
+	}
+	return fromFields(values), nil
+}
+
+func (p *commandProvider) run(key, field string) ([]byte, error) {
+	command := strings.NewReplacer("{key}", shellQuote(key), "{field}", shellQuote(field)).Replace(p.command)
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
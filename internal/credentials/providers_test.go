@@ -0,0 +1,142 @@
+package credentials
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEnvProvider(t *testing.T) {
+	t.Setenv("BACKUP_PROD_DB_USER", "backup")
+	t.Setenv("BACKUP_PROD_DB_PASSWORD", "secret")
+	t.Setenv("PROD_DB_USER", "unprefixed")
+
+	tests := []struct {
+		name    string
+		prefix  string
+		key     string
+		want    ServerCredentials
+		wantErr bool
+	}{
+		{name: "prefixed", prefix: "backup_", key: "prod-db", want: ServerCredentials{User: "backup", Password: "secret"}},
+		{name: "no prefix", key: "prod.db", want: ServerCredentials{User: "unprefixed"}},
+		{name: "nothing set", prefix: "backup_", key: "staging", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := (&envProvider{prefix: tt.prefix}).GetCredential(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDirectoryProvider(t *testing.T) {
+	root := t.TempDir()
+	secrets := filepath.Join(root, "prod", "db")
+	if err := os.MkdirAll(secrets, 0700); err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range map[string]string{"user": "backup\n", "password": "secret\r\n", "unrelated": "x"} {
+		if err := os.WriteFile(filepath.Join(secrets, name), []byte(value), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(root, "empty"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		key     string
+		want    ServerCredentials
+		wantErr bool
+	}{
+		{name: "nested key", key: "prod/db", want: ServerCredentials{User: "backup", Password: "secret"}},
+		{name: "empty directory", key: "empty", wantErr: true},
+		{name: "missing directory", key: "staging", wantErr: true},
+		{name: "escapes the root", key: "../etc", wantErr: true},
+		{name: "root itself", key: ".", wantErr: true},
+	}
+	provider := &directoryProvider{path: root}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := provider.GetCredential(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandProvider(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		key     string
+		want    ServerCredentials
+		wantErr string
+	}{
+		{
+			name:    "whole entry as yaml",
+			command: `printf 'user: %s\npassword: secret\n' {key}`,
+			key:     "backup",
+			want:    ServerCredentials{User: "backup", Password: "secret"},
+		},
+		{
+			name:    "one call per field",
+			command: `case {field} in user) echo {key} ;; password) echo secret ;; *) exit 1 ;; esac`,
+			key:     "backup",
+			want:    ServerCredentials{User: "backup", Password: "secret"},
+		},
+		{
+			name:    "quotes the key",
+			command: `[ {field} = user ] && echo {key}`,
+			key:     "it's; exit 1",
+			want:    ServerCredentials{User: "it's; exit 1"},
+		},
+		{
+			name:    "every field fails",
+			command: `echo no {field} for {key} >&2; exit 1`,
+			key:     "prod",
+			wantErr: "user: exit status 1: no user for prod",
+		},
+		{
+			name:    "command fails",
+			command: `echo denied >&2; exit 2`,
+			key:     "prod",
+			wantErr: "denied",
+		},
+		{
+			name:    "invalid yaml",
+			command: `echo '[unclosed'`,
+			key:     "prod",
+			wantErr: "invalid command output",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := (&commandProvider{command: tt.command}).GetCredential(tt.key)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetCredential: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
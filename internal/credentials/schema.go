@@ -0,0 +1,124 @@
+package credentials
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Credential types. Entries without a type are accepted for any purpose, as
+// older credentials files have none.
+const (
+	TypeSSH      = "ssh"
+	TypeDatabase = "database"
+	TypeTLS      = "tls"
+)
+
+// ServerCredentials is one entry of the credentials file. Which fields an
+// entry may set depends on its type.
+type ServerCredentials struct {
+	Type           string `yaml:"type,omitempty"`
+	User           string `yaml:"user,omitempty"`
+	SSHPassword    string `yaml:"ssh_password,omitempty"`
+	PrivateKey     string `yaml:"private_key,omitempty"`
+	Certificate    string `yaml:"certificate,omitempty"`
+	Passphrase     string `yaml:"passphrase,omitempty"`
+	BecomePassword string `yaml:"become_password,omitempty"`
+	Password       string `yaml:"password,omitempty"`
+	SSLCA          string `yaml:"ssl_ca,omitempty"`
+	SSLCert        string `yaml:"ssl_cert,omitempty"`
+	SSLKey         string `yaml:"ssl_key,omitempty"`
+}
+
+// Fields lists the credential fields by their name in the credentials file.
+var Fields = []string{"type", "user", "ssh_password", "private_key", "certificate", "passphrase", "become_password", "password", "ssl_ca", "ssl_cert", "ssl_key"}
+
+// typeFields lists the fields each type may set, besides the type itself.
+var typeFields = map[string][]string{
+	TypeSSH:      {"user", "ssh_password", "private_key", "certificate", "passphrase", "become_password"},
+	TypeDatabase: {"user", "password", "ssl_ca", "ssl_cert", "ssl_key"},
+	TypeTLS:      {"ssl_ca", "ssl_cert", "ssl_key"},
+}
+
+func (c *ServerCredentials) field(name string) (*string, error) {
+	switch name {
+	case "type":
+		return &c.Type, nil
+	case "user":
+		return &c.User, nil
+	case "ssh_password":
+		return &c.SSHPassword, nil
+	case "private_key":
+		return &c.PrivateKey, nil
+	case "certificate":
+		return &c.Certificate, nil
+	case "passphrase":
+		return &c.Passphrase, nil
+	case "become_password":
+		return &c.BecomePassword, nil
+	case "password":
+		return &c.Password, nil
+	case "ssl_ca":
+		return &c.SSLCA, nil
+	case "ssl_cert":
+		return &c.SSLCert, nil
+	case "ssl_key":
+		return &c.SSLKey, nil
+	}
+	return nil, fmt.Errorf("unknown credential field: %s", name)
+}
+
+// Get returns the value of the field called name in the credentials file.
+func (c ServerCredentials) Get(name string) (string, error) {
+	value, err := c.field(name)
+	if err != nil {
+		return "", err
+	}
+	return *value, nil
+}
+
+// Set changes the field called name in the credentials file.
+func (c *ServerCredentials) Set(name, value string) error {
+	field, err := c.field(name)
+	if err != nil {
+		return err
+	}
+	*field = value
+	return nil
+}
+
+// Validate checks that a typed entry only sets the fields of its type.
+func (c ServerCredentials) Validate() error {
+	if c.Type == "" {
+		return nil
+	}
+
+	allowed, ok := typeFields[c.Type]
+	if !ok {
+		return fmt.Errorf("unknown credential type: %s", c.Type)
+	}
+
+	for _, name := range Fields {
+		if value, _ := c.Get(name); value == "" || name == "type" || contains(allowed, name) {
+			continue
+		}
+		return fmt.Errorf("field %s is not allowed in %s credentials, use one of: %s", name, c.Type, strings.Join(allowed, ", "))
+	}
+	return nil
+}
+
+// checkType fails when a typed entry is used for a different purpose.
+func (c ServerCredentials) checkType(want string) error {
+	if c.Type != "" && c.Type != want {
+		return fmt.Errorf("is a %s credential, expected %s", c.Type, want)
+	}
+	return c.Validate()
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,28 @@
+package credentials
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cred    ServerCredentials
+		wantErr bool
+	}{
+		{name: "untyped allows anything", cred: ServerCredentials{User: "root", SSHPassword: "x", Password: "y", SSLCA: "ca"}},
+		{name: "ssh", cred: ServerCredentials{Type: TypeSSH, User: "deploy", PrivateKey: "key", Passphrase: "p", BecomePassword: "b"}},
+		{name: "ssh with database password", cred: ServerCredentials{Type: TypeSSH, Password: "y"}, wantErr: true},
+		{name: "database", cred: ServerCredentials{Type: TypeDatabase, User: "backup", Password: "y", SSLKey: "key"}},
+		{name: "database with ssh password", cred: ServerCredentials{Type: TypeDatabase, SSHPassword: "x"}, wantErr: true},
+		{name: "tls", cred: ServerCredentials{Type: TypeTLS, SSLCA: "ca", SSLCert: "cert", SSLKey: "key"}},
+		{name: "tls with user", cred: ServerCredentials{Type: TypeTLS, User: "backup"}, wantErr: true},
+		{name: "unknown type", cred: ServerCredentials{Type: "mysql"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cred.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
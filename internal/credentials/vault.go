@@ -0,0 +1,90 @@
+package credentials
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lucasberto/database-backup-tool/internal/config"
+)
+
+// vaultProvider reads secrets from a HashiCorp Vault KV version 2 engine.
+// The key is the secret path below the mount, and the fields of the secret
+// are the credential fields.
+type vaultProvider struct {
+	address   string
+	mount     string
+	namespace string
+	tokenEnv  string
+	client    *http.Client
+}
+
+func newVaultProvider(provider config.CredentialProvider) *vaultProvider {
+	mount := provider.Mount
+	if mount == "" {
+		mount = "secret"
+	}
+	tokenEnv := provider.TokenEnv
+	if tokenEnv == "" {
+		tokenEnv = "VAULT_TOKEN"
+	}
+
+	return &vaultProvider{
+		address:   strings.TrimRight(provider.Address, "/"),
+		mount:     strings.Trim(mount, "/"),
+		namespace: provider.Namespace,
+		tokenEnv:  tokenEnv,
+		client:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (p *vaultProvider) GetCredential(key string) (ServerCredentials, error) {
+	token := os.Getenv(p.tokenEnv)
+	if token == "" {
+		return ServerCredentials{}, fmt.Errorf("%s is not set", p.tokenEnv)
+	}
+
+	url := fmt.Sprintf("%s/v1/%s/data/%s", p.address, p.mount, strings.Trim(key, "/"))
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return ServerCredentials{}, err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if p.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.namespace)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return ServerCredentials{}, fmt.Errorf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Data struct {
+			Data map[string]any `json:"data"`
+		} `json:"data"`
+		Errors []string `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil && resp.StatusCode == http.StatusOK {
+		return ServerCredentials{}, fmt.Errorf("invalid response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return ServerCredentials{}, fmt.Errorf("reading %s: %s %s", key, resp.Status, strings.Join(body.Errors, ", "))
+	}
+
+	values := map[string]string{}
+	for field, value := range body.Data.Data {
+		if s, ok := value.(string); ok {
+			values[field] = s
+		}
+	}
+	cred := fromFields(values)
+	if cred == (ServerCredentials{}) {
+		return ServerCredentials{}, fmt.Errorf("secret %s has no credential fields", key)
+	}
+	return cred, nil
+}
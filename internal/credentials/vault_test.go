@@ -0,0 +1,73 @@
+package credentials
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lucasberto/database-backup-tool/internal/config"
+)
+
+func TestVaultProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		if got := r.Header.Get("X-Vault-Namespace"); got != "team" {
+			t.Errorf("namespace header %q, want team", got)
+		}
+		switch r.URL.Path {
+		case "/v1/kv/data/prod/db":
+			w.Write([]byte(`{"data":{"data":{"user":"backup","password":"secret","note":"ignored"},"metadata":{"version":3}}}`))
+		case "/v1/kv/data/prod/empty":
+			w.Write([]byte(`{"data":{"data":{"note":"nothing useful"}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	defer server.Close()
+
+	provider := newVaultProvider(config.CredentialProvider{
+		Address:   server.URL + "/",
+		Mount:     "/kv/",
+		Namespace: "team",
+		TokenEnv:  "TEST_VAULT_TOKEN",
+	})
+
+	tests := []struct {
+		name    string
+		token   string
+		key     string
+		want    ServerCredentials
+		wantErr string
+	}{
+		{name: "unwraps kv v2 data", token: "token", key: "prod/db", want: ServerCredentials{User: "backup", Password: "secret"}},
+		{name: "trims slashes", token: "token", key: "/prod/db/", want: ServerCredentials{User: "backup", Password: "secret"}},
+		{name: "missing secret", token: "token", key: "prod/missing", wantErr: "404"},
+		{name: "no credential fields", token: "token", key: "prod/empty", wantErr: "no credential fields"},
+		{name: "wrong token", token: "other", key: "prod/db", wantErr: "permission denied"},
+		{name: "no token", key: "prod/db", wantErr: "TEST_VAULT_TOKEN is not set"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TEST_VAULT_TOKEN", tt.token)
+			got, err := provider.GetCredential(tt.key)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetCredential: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
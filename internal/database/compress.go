@@ -1,29 +1,28 @@
-package mysql
+package database
 
 import (
-	"bytes"
 	"compress/gzip"
+	"io"
 
 	"github.com/vbauerster/mpb/v8"
 )
 
+// CompressedProgressWriter gzips everything written to it into the
+// underlying writer and reports the compressed byte count on bar.
 type CompressedProgressWriter struct {
 	*ProgressWriter
 	gzipWriter *gzip.Writer
-	buffer     *bytes.Buffer
 }
 
-func NewCompressedProgressWriter(bar *mpb.Bar) *CompressedProgressWriter {
-	buf := &bytes.Buffer{}
+func NewCompressedProgressWriter(w io.Writer, bar *mpb.Bar) *CompressedProgressWriter {
 	pw := &ProgressWriter{
-		Writer: buf,
+		Writer: w,
 		Bar:    bar,
 	}
 
 	return &CompressedProgressWriter{
 		ProgressWriter: pw,
 		gzipWriter:     gzip.NewWriter(pw),
-		buffer:         buf,
 	}
 }
 
@@ -37,8 +36,3 @@ func (cpw *CompressedProgressWriter) Close() error {
 	}
 	return cpw.ProgressWriter.Close()
 }
-
-func (cpw *CompressedProgressWriter) Bytes() []byte {
-	cpw.Close()
-	return cpw.buffer.Bytes()
-}
@@ -1,7 +1,28 @@
 package database
 
-import "github.com/lucasberto/database-backup-tool/internal/ssh"
+import (
+	"io"
 
-type Backup interface {
-	Dump(sshClient *ssh.Client, dbName, user, password string) ([]byte, error)
+	"github.com/lucasberto/database-backup-tool/internal/executor"
+	"github.com/lucasberto/database-backup-tool/internal/storage"
+	"github.com/vbauerster/mpb/v8"
+)
+
+// Engine backs up and restores one kind of database server over SSH. An
+// engine is created per server and keeps the remote state set up by Prepare,
+// such as temporary credential files, until Cleanup is called.
+type Engine interface {
+	Prepare(host executor.Executor) error
+	Cleanup(host executor.Executor) error
+	ListDatabases(host executor.Executor) ([]string, error)
+
+	// Dump streams a compressed backup of dbName into w. Engine specific
+	// details, such as replication coordinates, are recorded in manifest.
+	Dump(host executor.Executor, dbName string, w io.Writer, progress *mpb.Progress, manifest *storage.Manifest) error
+
+	// Restore loads a backup of source read from r into target.
+	Restore(host executor.Executor, source, target string, r io.Reader, progress *mpb.Progress) error
+
+	// Extension is appended to the names of backup files.
+	Extension() string
 }
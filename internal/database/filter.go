@@ -0,0 +1,75 @@
+package database
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// GlobPrefix marks a filter pattern as a shell-style glob instead of a
+// regular expression, e.g. "glob:app_*".
+const GlobPrefix = "glob:"
+
+// Filter selects database names using lists of include and exclude patterns.
+// A name is selected when it matches any include pattern (or no include
+// patterns are set) and matches none of the exclude patterns.
+type Filter struct {
+	include []matcher
+	exclude []matcher
+}
+
+type matcher func(name string) bool
+
+func NewFilter(include, exclude []string) (*Filter, error) {
+	inc, err := compilePatterns(include)
+	if err != nil {
+		return nil, fmt.Errorf("invalid include pattern: %v", err)
+	}
+
+	exc, err := compilePatterns(exclude)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exclude pattern: %v", err)
+	}
+
+	return &Filter{include: inc, exclude: exc}, nil
+}
+
+func (f *Filter) Match(name string) bool {
+	if len(f.include) > 0 && !matchAny(f.include, name) {
+		return false
+	}
+	return !matchAny(f.exclude, name)
+}
+
+func compilePatterns(patterns []string) ([]matcher, error) {
+	compiled := make([]matcher, 0, len(patterns))
+	for _, pattern := range patterns {
+		if glob, ok := strings.CutPrefix(pattern, GlobPrefix); ok {
+			if _, err := path.Match(glob, ""); err != nil {
+				return nil, fmt.Errorf("%s: %v", pattern, err)
+			}
+			compiled = append(compiled, func(name string) bool {
+				matched, _ := path.Match(glob, name)
+				return matched
+			})
+			continue
+		}
+
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, re.MatchString)
+	}
+	return compiled, nil
+}
+
+func matchAny(matchers []matcher, name string) bool {
+	for _, match := range matchers {
+		if match(name) {
+			return true
+		}
+	}
+	return false
+}
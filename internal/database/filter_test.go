@@ -0,0 +1,63 @@
+package database
+
+import "testing"
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		match   map[string]bool
+		wantErr bool
+	}{
+		{
+			name:  "no patterns",
+			match: map[string]bool{"app": true, "mysql": true},
+		},
+		{
+			name:    "regular expressions",
+			include: []string{"^app_", "^shop$"},
+			match:   map[string]bool{"app_prod": true, "shop": true, "shop_old": false, "myapp_prod": false},
+		},
+		{
+			name:    "unanchored expression",
+			include: []string{"prod"},
+			match:   map[string]bool{"app_prod": true, "production": true, "staging": false},
+		},
+		{
+			name:    "globs",
+			include: []string{"glob:app_*"},
+			exclude: []string{"glob:*_test"},
+			match:   map[string]bool{"app_prod": true, "app_test": false, "other": false},
+		},
+		{
+			name:    "exclude only",
+			exclude: []string{"^(information_schema|performance_schema)$"},
+			match:   map[string]bool{"information_schema": false, "app": true},
+		},
+		{
+			name:    "exclude wins",
+			include: []string{"glob:*"},
+			exclude: []string{"^tmp"},
+			match:   map[string]bool{"tmp_data": false, "data": true},
+		},
+		{name: "invalid expression", include: []string{"app_("}, wantErr: true},
+		{name: "invalid glob", exclude: []string{"glob:["}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := NewFilter(tt.include, tt.exclude)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			for name, want := range tt.match {
+				if got := filter.Match(name); got != want {
+					t.Errorf("Match(%q) = %v, want %v", name, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package mongodb
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/lucasberto/database-backup-tool/internal/config"
+	"github.com/lucasberto/database-backup-tool/internal/database"
+	"github.com/lucasberto/database-backup-tool/internal/executor"
+	"github.com/lucasberto/database-backup-tool/internal/storage"
+	"github.com/vbauerster/mpb/v8"
+)
+
+// systemDatabases matches the databases MongoDB manages itself. It is used as
+// the exclude list when a server does not configure one.
+var systemDatabases = []string{`^(admin|config|local)$`}
+
+const defaultPort = 27017
+
+type MongoDB struct {
+	config     config.Database
+	configPath string
+	scriptPath string
+}
+
+func New(dbConfig config.Database) *MongoDB {
+	return &MongoDB{config: dbConfig}
+}
+
+// Prepare writes the password to temporary files on the remote host: a
+// config file for mongodump and mongorestore, and a mongosh script that
+// connects and authenticates for listing databases. Neither tool then needs
+// the password on its command line.
+func (m *MongoDB) Prepare(host executor.Executor) error {
+	if m.config.Password != "" {
+		// JSON strings are valid YAML double-quoted scalars.
+		password, _ := json.Marshal(m.config.Password)
+		configPath, err := database.WriteTempFile(host, "mongodump", fmt.Sprintf("password: %s\n", password))
+		if err != nil {
+			return fmt.Errorf("failed to create config file: %v", err)
+		}
+		m.configPath = configPath
+	}
+
+	scriptPath, err := database.WriteTempFile(host, "mongosh", m.listScript())
+	if err != nil {
+		return fmt.Errorf("failed to create script file: %v", err)
+	}
+	m.scriptPath = scriptPath
+	return nil
+}
+
+// Cleanup removes the files written by Prepare.
+func (m *MongoDB) Cleanup(host executor.Executor) error {
+	if err := database.RemoveFiles(host, m.configPath, m.scriptPath); err != nil {
+		return err
+	}
+	m.configPath = ""
+	m.scriptPath = ""
+	return nil
+}
+
+func (m *MongoDB) Extension() string {
+	return ".archive.gz"
+}
+
+func (m *MongoDB) listScript() string {
+	uri, _ := json.Marshal(fmt.Sprintf("mongodb://127.0.0.1:%d/%s", m.port(), m.authDatabase()))
+
+	var script strings.Builder
+	fmt.Fprintf(&script, "db = connect(%s);\n", uri)
+	if m.config.User != "" {
+		user, _ := json.Marshal(m.config.User)
+		password, _ := json.Marshal(m.config.Password)
+		fmt.Fprintf(&script, "db.auth(%s, %s);\n", user, password)
+	}
+	script.WriteString("db.adminCommand({listDatabases: 1, nameOnly: true}).databases.forEach(d => print(d.name));\n")
+	return script.String()
+}
+
+// ListDatabases returns the databases on the server that pass the configured
+// include and exclude patterns.
+func (m *MongoDB) ListDatabases(host executor.Executor) ([]string, error) {
+	if m.scriptPath == "" {
+		return nil, fmt.Errorf("script file not created")
+	}
+
+	exclude := m.config.Exclude
+	if exclude == nil {
+		exclude = systemDatabases
+	}
+
+	filter, err := database.NewFilter(m.config.Include, exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := database.Run(host, "mongosh --nodb --quiet --file "+database.ShellQuote(m.scriptPath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list databases: %v", err)
+	}
+
+	var databases []string
+	for _, name := range strings.Split(output, "\n") {
+		name = strings.TrimSpace(name)
+		if name != "" && filter.Match(name) {
+			databases = append(databases, name)
+		}
+	}
+	return databases, nil
+}
+
+// Dump streams a mongodump archive of dbName into w. mongodump compresses
+// the archive itself.
+func (m *MongoDB) Dump(host executor.Executor, dbName string, w io.Writer, progress *mpb.Progress, manifest *storage.Manifest) error {
+	args := append([]string{"mongodump"}, m.connectionArgs()...)
+	args = append(args, database.ShellQuote("--db="+dbName), "--archive", "--gzip")
+
+	if err := database.StreamRaw(host, "Dumping "+dbName, strings.Join(args, " "), w, progress); err != nil {
+		return fmt.Errorf("mongodump failed: %v", err)
+	}
+	return nil
+}
+
+// Restore loads the archive of source read from r with mongorestore,
+// renaming its namespaces to target. Existing collections are dropped first,
+// like the DROP TABLE statements of a MySQL dump.
+func (m *MongoDB) Restore(host executor.Executor, source, target string, r io.Reader, progress *mpb.Progress) error {
+	args := append([]string{"mongorestore"}, m.connectionArgs()...)
+	args = append(args, "--archive", "--gzip", "--drop", database.ShellQuote("--nsInclude="+source+".*"))
+	if target != source {
+		args = append(args,
+			database.ShellQuote("--nsFrom="+source+".*"),
+			database.ShellQuote("--nsTo="+target+".*"),
+		)
+	}
+
+	if err := database.FeedRaw(host, "Restoring "+target, strings.Join(args, " "), r, progress); err != nil {
+		return fmt.Errorf("mongorestore failed: %v", err)
+	}
+	return nil
+}
+
+func (m *MongoDB) connectionArgs() []string {
+	args := []string{"--host=127.0.0.1", "--port=" + strconv.Itoa(m.port())}
+	if m.config.User != "" {
+		args = append(args,
+			database.ShellQuote("--username="+m.config.User),
+			database.ShellQuote("--authenticationDatabase="+m.authDatabase()),
+		)
+	}
+	if m.configPath != "" {
+		args = append(args, "--config="+database.ShellQuote(m.configPath))
+	}
+	return args
+}
+
+func (m *MongoDB) port() int {
+	if m.config.Port == 0 {
+		return defaultPort
+	}
+	return m.config.Port
+}
+
+func (m *MongoDB) authDatabase() string {
+	if m.config.AuthDatabase == "" {
+		return "admin"
+	}
+	return m.config.AuthDatabase
+}
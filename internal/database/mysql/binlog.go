@@ -0,0 +1,201 @@
+package mysql
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lucasberto/database-backup-tool/internal/database"
+	"github.com/lucasberto/database-backup-tool/internal/executor"
+	"github.com/vbauerster/mpb/v8"
+)
+
+// BinlogPosition is a point in the binary log, as recorded by mysqldump
+// --master-data.
+type BinlogPosition struct {
+	File     string
+	Position uint64
+}
+
+// BinlogRange holds the timestamps of the first and last events in a binary
+// log file.
+type BinlogRange struct {
+	FirstEvent time.Time
+	LastEvent  time.Time
+}
+
+// ListBinaryLogs returns the binary log files known to the server, oldest
+// first. The last entry is the log currently being written.
+func (m *MySQL) ListBinaryLogs(host executor.Executor) ([]string, error) {
+	if err := m.writeConfigFile(host); err != nil {
+		return nil, err
+	}
+
+	output, err := database.Run(host, fmt.Sprintf("mysql --defaults-file=%s -N -B -e 'SHOW BINARY LOGS'", database.ShellQuote(m.configPath)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list binary logs: %v", err)
+	}
+
+	var logs []string
+	for _, line := range strings.Split(output, "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			logs = append(logs, fields[0])
+		}
+	}
+	return logs, nil
+}
+
+// FlushBinaryLogs closes the current binary log so it can be archived.
+func (m *MySQL) FlushBinaryLogs(host executor.Executor) error {
+	if err := m.writeConfigFile(host); err != nil {
+		return err
+	}
+
+	if _, err := database.Run(host, fmt.Sprintf("mysql --defaults-file=%s -e 'FLUSH BINARY LOGS'", database.ShellQuote(m.configPath))); err != nil {
+		return fmt.Errorf("failed to flush binary logs: %v", err)
+	}
+	return nil
+}
+
+// ArchiveBinlog streams the raw binary log file name, gzip-compressed, into
+// w. mysqlbinlog fetches it through the replication protocol into a
+// temporary directory, so no access to the data directory is needed.
+func (m *MySQL) ArchiveBinlog(host executor.Executor, name string, w io.Writer, progress *mpb.Progress) (*BinlogRange, error) {
+	if err := m.writeConfigFile(host); err != nil {
+		return nil, err
+	}
+
+	fetch := fmt.Sprintf(`cd "$d" && mysqlbinlog --defaults-file=%s --read-from-remote-server --raw %s`,
+		database.ShellQuote(m.configPath),
+		database.ShellQuote(name),
+	)
+	cmd := `d=$(mktemp -d /tmp/binlog.XXXXXXXXXX) || exit 1; trap 'rm -rf "$d"' EXIT; ` +
+		database.Staged(fetch) +
+		fmt.Sprintf(`cat "$d"/%s`, database.ShellQuote(name))
+
+	scanner := &binlogScanner{}
+	if err := database.Stream(host, "Archiving "+name, cmd, w, progress, scanner); err != nil {
+		return nil, fmt.Errorf("mysqlbinlog failed: %v", err)
+	}
+	if scanner.err != nil {
+		return nil, fmt.Errorf("invalid binary log %s: %v", name, scanner.err)
+	}
+
+	return &BinlogRange{
+		FirstEvent: scanner.first,
+		LastEvent:  scanner.last,
+	}, nil
+}
+
+// SplitBinlogName splits a binary log file name such as mysql-bin.000042
+// into its base name and sequence number.
+func SplitBinlogName(name string) (string, int, error) {
+	i := strings.LastIndex(name, ".")
+	if i < 0 {
+		return "", 0, fmt.Errorf("invalid binlog name: %s", name)
+	}
+
+	seq, err := strconv.Atoi(name[i+1:])
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid binlog name: %s", name)
+	}
+	return name[:i], seq, nil
+}
+
+var positionPattern = regexp.MustCompile(`CHANGE (?:MASTER|REPLICATION SOURCE) TO (?:MASTER|SOURCE)_LOG_FILE='([^']+)', (?:MASTER|SOURCE)_LOG_POS=(\d+)`)
+
+// positionScanLimit bounds how much of a dump is searched for the binlog
+// coordinates. mysqldump writes them right after the header.
+const positionScanLimit = 64 * 1024
+
+// positionScanner looks for the coordinates written by --master-data in the
+// beginning of a dump.
+type positionScanner struct {
+	buf []byte
+}
+
+func (s *positionScanner) Write(p []byte) (int, error) {
+	if room := positionScanLimit - len(s.buf); room > 0 {
+		s.buf = append(s.buf, p[:min(room, len(p))]...)
+	}
+	return len(p), nil
+}
+
+func (s *positionScanner) Position() *BinlogPosition {
+	match := positionPattern.FindSubmatch(s.buf)
+	if match == nil {
+		return nil
+	}
+
+	position, err := strconv.ParseUint(string(match[2]), 10, 64)
+	if err != nil {
+		return nil
+	}
+	return &BinlogPosition{File: string(match[1]), Position: position}
+}
+
+const (
+	binlogMagic      = "\xfebin"
+	binlogHeaderSize = 19
+)
+
+// binlogScanner walks the v4 event headers of a raw binary log as it is
+// written and records the timestamps of the first and last events.
+type binlogScanner struct {
+	magic  int
+	header [binlogHeaderSize]byte
+	filled int
+	skip   int64
+	first  time.Time
+	last   time.Time
+	err    error
+}
+
+func (s *binlogScanner) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 && s.err == nil {
+		switch {
+		case s.magic < len(binlogMagic):
+			if p[0] != binlogMagic[s.magic] {
+				s.err = fmt.Errorf("missing binlog magic number")
+			}
+			s.magic++
+			p = p[1:]
+		case s.skip > 0:
+			k := min(int64(len(p)), s.skip)
+			s.skip -= k
+			p = p[k:]
+		default:
+			k := copy(s.header[s.filled:], p)
+			s.filled += k
+			p = p[k:]
+			if s.filled == binlogHeaderSize {
+				s.readHeader()
+			}
+		}
+	}
+	return n, nil
+}
+
+func (s *binlogScanner) readHeader() {
+	s.filled = 0
+
+	size := int64(binary.LittleEndian.Uint32(s.header[9:13]))
+	if size < binlogHeaderSize {
+		s.err = fmt.Errorf("invalid event size %d", size)
+		return
+	}
+	s.skip = size - binlogHeaderSize
+
+	if timestamp := binary.LittleEndian.Uint32(s.header[0:4]); timestamp != 0 {
+		eventTime := time.Unix(int64(timestamp), 0)
+		if s.first.IsZero() {
+			s.first = eventTime
+		}
+		s.last = eventTime
+	}
+}
@@ -1,111 +1,309 @@
 package mysql
 
 import (
-	"bytes"
 	"fmt"
+	"io"
+	"strconv"
 	"strings"
+	"sync"
 
-	"github.com/lucasberto/database-backup-tool/internal/ssh"
+	"github.com/lucasberto/database-backup-tool/internal/config"
+	"github.com/lucasberto/database-backup-tool/internal/database"
+	"github.com/lucasberto/database-backup-tool/internal/executor"
+	"github.com/lucasberto/database-backup-tool/internal/storage"
 	"github.com/vbauerster/mpb/v8"
-	"github.com/vbauerster/mpb/v8/decor"
 )
 
-type MySQL struct{}
+// systemDatabases matches the schemas MySQL manages itself. It is used as the
+// exclude list when a server does not configure one.
+var systemDatabases = []string{`^(information_schema|performance_schema|mysql|sys)$`}
 
-func New() *MySQL {
-	return &MySQL{}
+type MySQL struct {
+	config     config.Database
+	configPath string
+	tempFiles  []string
+
+	configMu       sync.Mutex
+	sourceDataOnce sync.Once
+	sourceData     bool
 }
 
-func (m *MySQL) CreateConfigFile(sshClient *ssh.Client, user, password string, port int) error {
-	session, err := sshClient.GetSSHClient().NewSession()
-	if err != nil {
-		return fmt.Errorf("failed to create session: %v", err)
+func New(dbConfig config.Database) *MySQL {
+	return &MySQL{config: dbConfig}
+}
+
+// Prepare writes a client options file with the connection credentials to a
+// unique temporary path on the remote host. The native dumper connects
+// through the SSH connection instead, so for it the file is only written
+// once restores, physical backups or binlog archiving need it.
+func (m *MySQL) Prepare(host executor.Executor) error {
+	if m.config.Dumper == config.DumperNative {
+		return nil
+	}
+	return m.writeConfigFile(host)
+}
+
+// writeConfigFile writes the options file unless it exists already. The
+// contents are sent over stdin so the password never appears in the remote
+// command line. TLS material from the credentials file is uploaded the same
+// way.
+func (m *MySQL) writeConfigFile(host executor.Executor) error {
+	m.configMu.Lock()
+	defer m.configMu.Unlock()
+	if m.configPath != "" {
+		return nil
+	}
+
+	options := [][2]string{
+		{"user", m.config.User},
+		{"password", m.config.Password},
+	}
+	if m.config.Socket != "" {
+		options = append(options, [2]string{"socket", m.config.Socket})
+	} else {
+		options = append(options,
+			[2]string{"host", m.config.ConnectHost()},
+			[2]string{"port", strconv.Itoa(m.config.Port)})
+	}
+	if m.config.SSLMode != "" {
+		options = append(options, [2]string{"ssl-mode", strings.ToUpper(m.config.SSLMode)})
+	}
+
+	files := [][3]string{
+		{"ssl-ca", m.config.SSLCA, m.config.SSLCAData},
+		{"ssl-cert", m.config.SSLCert, m.config.SSLCertData},
+		{"ssl-key", m.config.SSLKey, m.config.SSLKeyData},
+	}
+	for _, file := range files {
+		path, err := m.tlsFile(host, file[1], file[2])
+		if err != nil {
+			return err
+		}
+		if path != "" {
+			options = append(options, [2]string{file[0], path})
+		}
 	}
-	defer session.Close()
 
-	tmpConfig := fmt.Sprintf(`[client]
-host=127.0.0.1
-user=%s
-password=%s
-port=%d`, user, password, port)
+	tmpConfig := "[client]\n"
+	for _, option := range options {
+		value, err := optionValue(option[1])
+		if err != nil {
+			return fmt.Errorf("invalid database %s: %v", option[0], err)
+		}
+		tmpConfig += option[0] + "=" + value + "\n"
+	}
 
-	setupCmd := fmt.Sprintf("rm -f /tmp/mydump.cnf && cat > /tmp/mydump.cnf << 'EOL'\n%s\nEOL\nchmod 600 /tmp/mydump.cnf", tmpConfig)
-	return session.Run(setupCmd)
+	configPath, err := database.WriteTempFile(host, "mydump", tmpConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create config file: %v", err)
+	}
+	m.configPath = configPath
+	return nil
 }
 
-func (m *MySQL) CleanupConfigFile(sshClient *ssh.Client) error {
-	session, err := sshClient.GetSSHClient().NewSession()
+// tlsFile returns the remote path of a TLS file. PEM contents from the
+// credentials file are uploaded to a temporary file, which Cleanup removes.
+func (m *MySQL) tlsFile(host executor.Executor, path, contents string) (string, error) {
+	if contents == "" {
+		return path, nil
+	}
+
+	tmpPath, err := database.WriteTempFile(host, "mytls", contents)
 	if err != nil {
-		return fmt.Errorf("failed to create session: %v", err)
+		return "", fmt.Errorf("failed to upload TLS file: %v", err)
+	}
+	m.tempFiles = append(m.tempFiles, tmpPath)
+	return tmpPath, nil
+}
+
+// Cleanup removes the options file and TLS files written by Prepare.
+func (m *MySQL) Cleanup(host executor.Executor) error {
+	if err := database.RemoveFiles(host, append([]string{m.configPath}, m.tempFiles...)...); err != nil {
+		return err
 	}
-	defer session.Close()
+	m.configPath = ""
+	m.tempFiles = nil
+	return nil
+}
 
-	return session.Run("rm -f /tmp/mydump.cnf")
+func (m *MySQL) Extension() string {
+	return ".sql.gz"
 }
 
-func (m *MySQL) Dump(sshClient *ssh.Client, dbName string, progress *mpb.Progress) ([]byte, error) {
+// Dump streams a gzip-compressed mysqldump of dbName into w, or a dump taken
+// by the native dumper when it is configured. When binlog
+// archiving is enabled the dump is taken with --source-data (--master-data
+// for older clients) and the binlog
+// coordinates it is consistent with are recorded in manifest, so restores
+// know where to start replaying.
+func (m *MySQL) Dump(host executor.Executor, dbName string, w io.Writer, progress *mpb.Progress, manifest *storage.Manifest) error {
+	if m.config.Dumper == config.DumperNative {
+		return m.nativeDump(host, dbName, w, progress, manifest)
+	}
+	if m.configPath == "" {
+		return fmt.Errorf("config file not created")
+	}
 
-	// create new session for the actual dump
-	session, err := sshClient.GetSSHClient().NewSession()
-	if err != nil {
-		return nil, fmt.Errorf("failed to create session: %v", err)
+	if m.config.Binlog.Enabled {
+		m.detectSourceData(host)
+	}
+	cmd := m.dumpCommand(dbName)
+	scanner := &positionScanner{}
+
+	if err := database.Stream(host, "Dumping "+dbName, cmd, w, progress, scanner); err != nil {
+		return fmt.Errorf("mysqldump failed: %v", err)
 	}
-	defer session.Close()
 
-	displayName := dbName
-	if len(dbName) > 40 {
-		displayName = dbName[:37] + "..."
+	if position := scanner.Position(); position != nil {
+		manifest.BinlogFile = position.File
+		manifest.BinlogPosition = position.Position
 	}
+	return nil
+}
+
+// detectSourceData checks once whether mysqldump knows --source-data, which
+// replaces --master-data from MySQL 8.0.26. MariaDB only has --master-data.
+func (m *MySQL) detectSourceData(host executor.Executor) {
+	m.sourceDataOnce.Do(func() {
+		help, err := database.Run(host, "mysqldump --help")
+		m.sourceData = err == nil && strings.Contains(help, "--source-data")
+	})
+}
 
-	bar := progress.New(-1,
-		mpb.BarStyle(),
-		mpb.BarRemoveOnComplete(),
-		mpb.PrependDecorators(
-			decor.Name(fmt.Sprintf("Dumping %s ", displayName), decor.WC{W: 45, C: decor.DindentRight}),
-			decor.CurrentKibiByte("%.2f"),
-		),
-	)
+// dumpCommand builds the mysqldump invocation for dbName. Excluded tables are
+// skipped entirely, while tables listed in IgnoreDataTables are left out of
+// the main dump and added back by a second, schema-only pass.
+func (m *MySQL) dumpCommand(dbName string) string {
+	defaultsFile := "--defaults-file=" + database.ShellQuote(m.configPath)
+	tables := m.config.Tables[dbName]
 
-	var stderr bytes.Buffer
+	args := []string{"mysqldump", defaultsFile}
+	for _, arg := range dumpOptionArgs(m.config.DumpOptions) {
+		args = append(args, database.ShellQuote(arg))
+	}
+	if m.config.Binlog.Enabled {
+		if m.sourceData {
+			args = append(args, "--source-data=2")
+		} else {
+			args = append(args, "--master-data=2")
+		}
+	}
+	for _, table := range tables.ExcludeTables {
+		args = append(args, database.ShellQuote("--ignore-table="+dbName+"."+table))
+	}
+	for _, table := range tables.IgnoreDataTables {
+		args = append(args, database.ShellQuote("--ignore-table="+dbName+"."+table))
+	}
+	args = append(args, database.ShellQuote(dbName))
+	cmd := strings.Join(args, " ")
 
-	cpw := NewCompressedProgressWriter(bar)
-	session.Stdout = cpw
-	session.Stderr = &stderr
-	defer cpw.Close()
+	if len(tables.IgnoreDataTables) > 0 {
+		schemaArgs := []string{"mysqldump", defaultsFile, "--no-data"}
+		for _, arg := range schemaOptionArgs(m.config.DumpOptions) {
+			schemaArgs = append(schemaArgs, database.ShellQuote(arg))
+		}
+		schemaArgs = append(schemaArgs, database.ShellQuote(dbName))
+		for _, table := range tables.IgnoreDataTables {
+			schemaArgs = append(schemaArgs, database.ShellQuote(table))
+		}
+		cmd += " && " + strings.Join(schemaArgs, " ")
+	}
 
-	cmd := fmt.Sprintf("mysqldump --defaults-file=/tmp/mydump.cnf %s", dbName)
+	return cmd
+}
 
-	err = session.Run(cmd)
-	if err != nil {
-		return nil, fmt.Errorf("mysqldump failed: %v: %s", err, stderr.String())
+// dumpOptionArgs translates options into mysqldump flags. Boolean options
+// that are not set explicitly are enabled.
+func dumpOptionArgs(options config.DumpOptions) []string {
+	var args []string
+	if enabled(options.SingleTransaction) {
+		args = append(args, "--single-transaction")
+	}
+	if enabled(options.Routines) {
+		args = append(args, "--routines")
+	}
+	if enabled(options.Triggers) {
+		args = append(args, "--triggers")
+	} else {
+		args = append(args, "--skip-triggers")
+	}
+	if enabled(options.Events) {
+		args = append(args, "--events")
+	}
+	if enabled(options.HexBlob) {
+		args = append(args, "--hex-blob")
 	}
+	if options.SetGTIDPurged != "" {
+		args = append(args, "--set-gtid-purged="+strings.ToUpper(options.SetGTIDPurged))
+	}
+	return append(args, options.ExtraArgs...)
+}
 
-	return cpw.Bytes(), nil
+// schemaOptionArgs returns the flags of the schema-only pass. It runs in
+// its own transaction like the main dump, but leaves routines and events to
+// the main dump and does not set GTID_PURGED a second time.
+func schemaOptionArgs(options config.DumpOptions) []string {
+	options.Routines = new(bool)
+	options.Events = new(bool)
+	if options.SetGTIDPurged != "" {
+		options.SetGTIDPurged = "OFF"
+	}
+	return dumpOptionArgs(options)
 }
 
-func (m *MySQL) ListDatabases(sshClient *ssh.Client, user, password string, port int) ([]string, error) {
-	session, err := sshClient.GetSSHClient().NewSession()
+func enabled(option *bool) bool {
+	return option == nil || *option
+}
+
+// ListDatabases returns the databases on the server that pass the configured
+// include and exclude patterns. It authenticates through the config file
+// written by Prepare, or connects directly when the native dumper is used.
+func (m *MySQL) ListDatabases(host executor.Executor) ([]string, error) {
+	exclude := m.config.Exclude
+	if exclude == nil {
+		exclude = systemDatabases
+	}
+
+	filter, err := database.NewFilter(m.config.Include, exclude)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create session: %v", err)
+		return nil, err
 	}
-	defer session.Close()
 
-	var stdout bytes.Buffer
-	var stderr bytes.Buffer
-	session.Stdout = &stdout
-	session.Stderr = &stderr
+	if m.config.Dumper == config.DumperNative {
+		return m.nativeListDatabases(host, filter)
+	}
+	if m.configPath == "" {
+		return nil, fmt.Errorf("config file not created")
+	}
 
-	cmd := fmt.Sprintf("mysql -h127.0.0.1 -P%d -u%s -p%s -N -e 'SHOW DATABASES' | grep -Ev '^(information_schema|performance_schema|mysql|sys)$'",
-		port,
-		user,
-		password,
-	)
+	cmd := fmt.Sprintf("mysql --defaults-file=%s -N -B -e 'SHOW DATABASES'", database.ShellQuote(m.configPath))
 
-	err = session.Run(cmd)
+	output, err := database.Run(host, cmd)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list databases: %v: %s", err, stderr.String())
+		return nil, fmt.Errorf("failed to list databases: %v", err)
 	}
 
-	databases := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	var databases []string
+	for _, name := range strings.Split(output, "\n") {
+		name = strings.TrimSpace(name)
+		if name != "" && filter.Match(name) {
+			databases = append(databases, name)
+		}
+	}
 	return databases, nil
 }
+
+// optionValue quotes a value for a MySQL option file. Option files have no
+// escape sequence for quote characters, so the value is wrapped in whichever
+// quote character it does not contain.
+func optionValue(value string) (string, error) {
+	escaped := strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`, "\t", `\t`).Replace(value)
+
+	switch {
+	case !strings.Contains(value, `"`):
+		return `"` + escaped + `"`, nil
+	case !strings.Contains(value, "'"):
+		return "'" + escaped + "'", nil
+	}
+	return "", fmt.Errorf("value cannot contain both single and double quotes")
+}
@@ -0,0 +1,32 @@
+package mysql
+
+import "testing"
+
+func TestOptionValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{name: "plain", value: "secret", want: `"secret"`},
+		{name: "empty", value: "", want: `""`},
+		{name: "comment and spaces", value: " pass #word ", want: `" pass #word "`},
+		{name: "single quote", value: "it's", want: `"it's"`},
+		{name: "double quote", value: `say "hi"`, want: `'say "hi"'`},
+		{name: "backslash", value: `a\b`, want: `"a\\b"`},
+		{name: "control characters", value: "a\nb\tc\r", want: `"a\nb\tc\r"`},
+		{name: "both quotes", value: `it's "quoted"`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := optionValue(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,740 @@
+package mysql
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/lucasberto/database-backup-tool/internal/config"
+	"github.com/lucasberto/database-backup-tool/internal/database"
+	"github.com/lucasberto/database-backup-tool/internal/executor"
+	"github.com/lucasberto/database-backup-tool/internal/storage"
+	"github.com/vbauerster/mpb/v8"
+)
+
+// insertBatchSize caps the length of the value list of a single INSERT
+// statement written by the native dumper.
+const insertBatchSize = 1 << 20
+
+// openNative connects to the server through a connection dialed from host,
+// so the MySQL protocol is spoken from Go over the SSH connection and no
+// client tools are needed on the remote side.
+func (m *MySQL) openNative(host executor.Executor, dbName string) (*sql.DB, error) {
+	dialer, ok := host.(executor.Dialer)
+	if !ok {
+		return nil, fmt.Errorf("transport does not support forwarding connections")
+	}
+
+	port := m.config.Port
+	if port == 0 {
+		port = 3306
+	}
+
+	cfg := gomysql.NewConfig()
+	cfg.User = m.config.User
+	cfg.Passwd = m.config.Password
+	cfg.Net = "tcp"
+	cfg.Addr = net.JoinHostPort(m.config.ConnectHost(), strconv.Itoa(port))
+	if m.config.Socket != "" {
+		cfg.Net = "unix"
+		cfg.Addr = m.config.Socket
+	}
+	cfg.DBName = dbName
+
+	if err := m.nativeTLS(host, cfg); err != nil {
+		return nil, err
+	}
+	cfg.DialFunc = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return dialer.Dial(network, addr)
+	}
+
+	connector, err := gomysql.NewConnector(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return sql.OpenDB(connector), nil
+}
+
+// nativeTLS configures TLS on cfg following ssl_mode, with the same meaning
+// as for the mysql client. Certificates configured as paths are read from the
+// remote host.
+func (m *MySQL) nativeTLS(host executor.Executor, cfg *gomysql.Config) error {
+	mode := strings.ToUpper(m.config.SSLMode)
+	switch mode {
+	case "", "DISABLED":
+		return nil
+	case "PREFERRED":
+		cfg.AllowFallbackToPlaintext = true
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: true}
+
+	if mode == "VERIFY_CA" || mode == "VERIFY_IDENTITY" {
+		ca, err := readTLSFile(host, m.config.SSLCA, m.config.SSLCAData)
+		if err != nil {
+			return err
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM([]byte(ca)) {
+			return fmt.Errorf("ssl_mode %s requires a CA certificate", mode)
+		}
+
+		if mode == "VERIFY_IDENTITY" {
+			tlsConfig = &tls.Config{RootCAs: roots, ServerName: m.config.ConnectHost()}
+		} else {
+			// Verify the chain but not the host name, which the standard
+			// verification cannot skip on its own.
+			tlsConfig.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+				return verifyChain(rawCerts, roots)
+			}
+		}
+	}
+
+	cert, err := readTLSFile(host, m.config.SSLCert, m.config.SSLCertData)
+	if err != nil {
+		return err
+	}
+	key, err := readTLSFile(host, m.config.SSLKey, m.config.SSLKeyData)
+	if err != nil {
+		return err
+	}
+	if cert != "" || key != "" {
+		pair, err := tls.X509KeyPair([]byte(cert), []byte(key))
+		if err != nil {
+			return fmt.Errorf("invalid client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{pair}
+	}
+
+	cfg.TLS = tlsConfig
+	return nil
+}
+
+// readTLSFile returns contents when set, otherwise the file at path on the
+// remote host.
+func readTLSFile(host executor.Executor, path, contents string) (string, error) {
+	if contents != "" || path == "" {
+		return contents, nil
+	}
+
+	data, err := database.Run(host, "cat "+database.ShellQuote(path))
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return data, nil
+}
+
+func verifyChain(rawCerts [][]byte, roots *x509.CertPool) error {
+	if len(rawCerts) == 0 {
+		return fmt.Errorf("server sent no certificate")
+	}
+
+	certs := make([]*x509.Certificate, len(rawCerts))
+	for i, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		certs[i] = cert
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+	return err
+}
+
+// nativeListDatabases lists the databases on the server over a forwarded
+// connection.
+func (m *MySQL) nativeListDatabases(host executor.Executor, filter *database.Filter) ([]string, error) {
+	db, err := m.openNative(host, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %v", err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SHOW DATABASES")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list databases: %v", err)
+	}
+	defer rows.Close()
+
+	var databases []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to list databases: %v", err)
+		}
+		if filter.Match(name) {
+			databases = append(databases, name)
+		}
+	}
+	return databases, rows.Err()
+}
+
+// nativeDump writes a gzip-compressed SQL dump of dbName into w without
+// running mysqldump. Everything is read inside a single transaction started
+// WITH CONSISTENT SNAPSHOT. When binlog archiving is enabled the tables are
+// locked briefly while the snapshot is taken so the binlog coordinates match
+// it exactly.
+func (m *MySQL) nativeDump(host executor.Executor, dbName string, w io.Writer, progress *mpb.Progress, manifest *storage.Manifest) error {
+	db, err := m.openNative(host, dbName)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	position, err := startSnapshot(ctx, conn, m.config.Binlog.Enabled)
+	if err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, "ROLLBACK")
+
+	cpw := database.NewCompressedProgressWriter(w, database.NewBar(progress, "Dumping "+dbName))
+	defer cpw.Close()
+	out := bufio.NewWriterSize(cpw, 64*1024)
+
+	d := &nativeDumper{
+		ctx:     ctx,
+		conn:    conn,
+		out:     out,
+		dbName:  dbName,
+		tables:  m.config.Tables[dbName],
+		options: m.config.DumpOptions,
+	}
+	if err := d.dump(position); err != nil {
+		return fmt.Errorf("native dump failed: %v", err)
+	}
+
+	if err := out.Flush(); err != nil {
+		return err
+	}
+	if err := cpw.Close(); err != nil {
+		return err
+	}
+
+	if position != nil {
+		manifest.BinlogFile = position.File
+		manifest.BinlogPosition = position.Position
+	}
+	return nil
+}
+
+// startSnapshot opens the transaction the dump is read in. With withPosition
+// set, writes are blocked until the snapshot exists and the binlog position
+// it corresponds to is returned.
+func startSnapshot(ctx context.Context, conn *sql.Conn, withPosition bool) (*BinlogPosition, error) {
+	setup := []string{
+		"SET SESSION TRANSACTION ISOLATION LEVEL REPEATABLE READ",
+		"SET SESSION time_zone = '+00:00'",
+		"SET SESSION sql_mode = ''",
+	}
+	for _, query := range setup {
+		if _, err := conn.ExecContext(ctx, query); err != nil {
+			return nil, fmt.Errorf("failed to prepare session: %v", err)
+		}
+	}
+
+	if !withPosition {
+		if _, err := conn.ExecContext(ctx, "START TRANSACTION WITH CONSISTENT SNAPSHOT"); err != nil {
+			return nil, fmt.Errorf("failed to start transaction: %v", err)
+		}
+		return nil, nil
+	}
+
+	if _, err := conn.ExecContext(ctx, "FLUSH TABLES WITH READ LOCK"); err != nil {
+		return nil, fmt.Errorf("failed to lock tables: %v", err)
+	}
+	defer conn.ExecContext(ctx, "UNLOCK TABLES")
+
+	if _, err := conn.ExecContext(ctx, "START TRANSACTION WITH CONSISTENT SNAPSHOT"); err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+
+	position, err := masterStatus(ctx, conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read binlog position: %v", err)
+	}
+
+	if _, err := conn.ExecContext(ctx, "UNLOCK TABLES"); err != nil {
+		return nil, fmt.Errorf("failed to unlock tables: %v", err)
+	}
+	return position, nil
+}
+
+// masterStatus reads the current binlog coordinates. MySQL 8.4 renamed
+// SHOW MASTER STATUS, so the new statement is tried when the old one fails.
+func masterStatus(ctx context.Context, conn *sql.Conn) (*BinlogPosition, error) {
+	row, err := queryRow(ctx, conn, "SHOW MASTER STATUS")
+	if err != nil {
+		row, err = queryRow(ctx, conn, "SHOW BINARY LOG STATUS")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if row == nil || !row["File"].Valid {
+		return nil, fmt.Errorf("binary logging is not enabled")
+	}
+
+	position, err := strconv.ParseUint(row["Position"].String, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid binlog position %q", row["Position"].String)
+	}
+	return &BinlogPosition{File: row["File"].String, Position: position}, nil
+}
+
+// queryRow returns the first row of query keyed by column name, or nil when
+// the query returns no rows. It suits the SHOW statements, whose column sets
+// vary between server versions.
+func queryRow(ctx context.Context, conn *sql.Conn, query string, args ...any) (map[string]sql.NullString, error) {
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+
+	values := make([]sql.NullString, len(columns))
+	pointers := make([]any, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	if err := rows.Scan(pointers...); err != nil {
+		return nil, err
+	}
+
+	row := make(map[string]sql.NullString, len(columns))
+	for i, column := range columns {
+		row[column] = values[i]
+	}
+	return row, nil
+}
+
+type nativeDumper struct {
+	ctx     context.Context
+	conn    *sql.Conn
+	out     *bufio.Writer
+	dbName  string
+	tables  config.TableFilter
+	options config.DumpOptions
+}
+
+func (d *nativeDumper) dump(position *BinlogPosition) error {
+	fmt.Fprintf(d.out, "-- Dump of database %s\n\n", quoteIdentifier(d.dbName))
+	if position != nil {
+		fmt.Fprintf(d.out, "-- CHANGE MASTER TO MASTER_LOG_FILE=%s, MASTER_LOG_POS=%d;\n\n", quoteString(position.File), position.Position)
+	}
+	d.out.WriteString("/*!40101 SET NAMES utf8mb4 */;\n" +
+		"/*!40103 SET TIME_ZONE='+00:00' */;\n" +
+		"/*!40014 SET UNIQUE_CHECKS=0 */;\n" +
+		"/*!40014 SET FOREIGN_KEY_CHECKS=0 */;\n" +
+		"/*!40101 SET SQL_MODE='NO_AUTO_VALUE_ON_ZERO' */;\n\n")
+
+	tables, views, err := d.listTables()
+	if err != nil {
+		return err
+	}
+
+	for _, table := range tables {
+		if err := d.writeTable(table, !contains(d.tables.IgnoreDataTables, table)); err != nil {
+			return err
+		}
+	}
+	if err := d.writeViews(views); err != nil {
+		return err
+	}
+	if enabled(d.options.Triggers) {
+		for _, table := range tables {
+			if err := d.writeTriggers(table); err != nil {
+				return err
+			}
+		}
+	}
+	if enabled(d.options.Routines) {
+		if err := d.writeRoutines(); err != nil {
+			return err
+		}
+	}
+	if enabled(d.options.Events) {
+		if err := d.writeEvents(); err != nil {
+			return err
+		}
+	}
+
+	d.out.WriteString("/*!40014 SET FOREIGN_KEY_CHECKS=1 */;\n" +
+		"/*!40014 SET UNIQUE_CHECKS=1 */;\n\n" +
+		"-- Dump completed\n")
+	return nil
+}
+
+// listTables returns the base tables and views of the database, leaving out
+// excluded tables.
+func (d *nativeDumper) listTables() ([]string, []string, error) {
+	rows, err := d.conn.QueryContext(d.ctx,
+		"SELECT TABLE_NAME, TABLE_TYPE FROM information_schema.TABLES WHERE TABLE_SCHEMA = ? ORDER BY TABLE_NAME", d.dbName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list tables: %v", err)
+	}
+	defer rows.Close()
+
+	var tables, views []string
+	for rows.Next() {
+		var name, tableType string
+		if err := rows.Scan(&name, &tableType); err != nil {
+			return nil, nil, fmt.Errorf("failed to list tables: %v", err)
+		}
+		if contains(d.tables.ExcludeTables, name) {
+			continue
+		}
+		switch tableType {
+		case "BASE TABLE":
+			tables = append(tables, name)
+		case "VIEW":
+			views = append(views, name)
+		}
+	}
+	return tables, views, rows.Err()
+}
+
+func (d *nativeDumper) writeTable(table string, withData bool) error {
+	qualified := quoteIdentifier(d.dbName) + "." + quoteIdentifier(table)
+
+	row, err := d.showCreate("SHOW CREATE TABLE " + qualified)
+	if err != nil {
+		return fmt.Errorf("failed to read definition of table %s: %v", table, err)
+	}
+
+	fmt.Fprintf(d.out, "--\n-- Table structure for table %s\n--\n\n", quoteIdentifier(table))
+	fmt.Fprintf(d.out, "DROP TABLE IF EXISTS %s;\n", quoteIdentifier(table))
+	fmt.Fprintf(d.out, "%s;\n\n", row["Create Table"].String)
+
+	if !withData {
+		return nil
+	}
+	return d.writeData(table)
+}
+
+// writeData writes the rows of table as multi-row INSERT statements.
+// Generated columns are computed by the server and cannot be inserted, so
+// they are left out of the column list.
+func (d *nativeDumper) writeData(table string) error {
+	columns, err := d.insertableColumns(table)
+	if err != nil {
+		return err
+	}
+	if len(columns) == 0 {
+		return nil
+	}
+
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = quoteIdentifier(column)
+	}
+	columnList := strings.Join(quoted, ",")
+
+	rows, err := d.conn.QueryContext(d.ctx,
+		fmt.Sprintf("SELECT %s FROM %s.%s", columnList, quoteIdentifier(d.dbName), quoteIdentifier(table)))
+	if err != nil {
+		return fmt.Errorf("failed to read table %s: %v", table, err)
+	}
+	defer rows.Close()
+
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return fmt.Errorf("failed to read table %s: %v", table, err)
+	}
+
+	values := make([]sql.RawBytes, len(columns))
+	pointers := make([]any, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	prefix := fmt.Sprintf("INSERT INTO %s (%s) VALUES ", quoteIdentifier(table), columnList)
+	fmt.Fprintf(d.out, "--\n-- Dumping data for table %s\n--\n\n", quoteIdentifier(table))
+
+	var batch int
+	var tuple strings.Builder
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return fmt.Errorf("failed to read table %s: %v", table, err)
+		}
+
+		tuple.Reset()
+		tuple.WriteByte('(')
+		for i, value := range values {
+			if i > 0 {
+				tuple.WriteByte(',')
+			}
+			writeValue(&tuple, value, types[i].DatabaseTypeName())
+		}
+		tuple.WriteByte(')')
+
+		if batch > 0 && batch+tuple.Len() > insertBatchSize {
+			d.out.WriteString(";\n")
+			batch = 0
+		}
+		if batch == 0 {
+			d.out.WriteString(prefix)
+		} else {
+			d.out.WriteByte(',')
+		}
+		d.out.WriteString(tuple.String())
+		batch += tuple.Len()
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read table %s: %v", table, err)
+	}
+
+	if batch > 0 {
+		d.out.WriteString(";\n")
+	}
+	d.out.WriteString("\n")
+	return nil
+}
+
+func (d *nativeDumper) insertableColumns(table string) ([]string, error) {
+	rows, err := d.conn.QueryContext(d.ctx,
+		"SELECT COLUMN_NAME FROM information_schema.COLUMNS "+
+			"WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? AND EXTRA NOT REGEXP '(VIRTUAL|STORED|PERSISTENT) GENERATED' "+
+			"ORDER BY ORDINAL_POSITION", d.dbName, table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns of table %s: %v", table, err)
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to read columns of table %s: %v", table, err)
+		}
+		columns = append(columns, name)
+	}
+	return columns, rows.Err()
+}
+
+// writeViews writes the view definitions, creating views that others
+// depend on first. Dependencies are detected by looking for the quoted name
+// of each pending view in the definitions, which is how the server prints
+// references.
+func (d *nativeDumper) writeViews(views []string) error {
+	definitions := make(map[string]string, len(views))
+	for _, view := range views {
+		row, err := d.showCreate("SHOW CREATE VIEW " + quoteIdentifier(d.dbName) + "." + quoteIdentifier(view))
+		if err != nil {
+			return fmt.Errorf("failed to read definition of view %s: %v", view, err)
+		}
+		definitions[view] = row["Create View"].String
+	}
+
+	pending := views
+	for len(pending) > 0 {
+		var next []string
+		for _, view := range pending {
+			if dependsOnAny(definitions[view], view, pending) {
+				next = append(next, view)
+				continue
+			}
+			d.writeView(view, definitions[view])
+		}
+
+		// A cycle cannot be resolved by ordering; write the rest as they are.
+		if len(next) == len(pending) {
+			for _, view := range next {
+				d.writeView(view, definitions[view])
+			}
+			break
+		}
+		pending = next
+	}
+	return nil
+}
+
+func (d *nativeDumper) writeView(view, definition string) {
+	fmt.Fprintf(d.out, "--\n-- View structure for view %s\n--\n\n", quoteIdentifier(view))
+	fmt.Fprintf(d.out, "DROP TABLE IF EXISTS %s;\n", quoteIdentifier(view))
+	fmt.Fprintf(d.out, "DROP VIEW IF EXISTS %s;\n", quoteIdentifier(view))
+	fmt.Fprintf(d.out, "%s;\n\n", definition)
+}
+
+func dependsOnAny(definition, self string, views []string) bool {
+	for _, view := range views {
+		if view != self && strings.Contains(definition, quoteIdentifier(view)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *nativeDumper) writeTriggers(table string) error {
+	names, err := d.names("SELECT TRIGGER_NAME FROM information_schema.TRIGGERS "+
+		"WHERE TRIGGER_SCHEMA = ? AND EVENT_OBJECT_TABLE = ? ORDER BY ACTION_ORDER", d.dbName, table)
+	if err != nil {
+		return fmt.Errorf("failed to list triggers of table %s: %v", table, err)
+	}
+
+	for _, name := range names {
+		row, err := d.showCreate("SHOW CREATE TRIGGER " + quoteIdentifier(d.dbName) + "." + quoteIdentifier(name))
+		if err != nil {
+			return fmt.Errorf("failed to read trigger %s: %v", name, err)
+		}
+		d.writeRoutine("DROP TRIGGER IF EXISTS "+quoteIdentifier(name), row["sql_mode"].String, row["SQL Original Statement"])
+	}
+	return nil
+}
+
+func (d *nativeDumper) writeRoutines() error {
+	for _, routineType := range []string{"FUNCTION", "PROCEDURE"} {
+		names, err := d.names("SELECT ROUTINE_NAME FROM information_schema.ROUTINES "+
+			"WHERE ROUTINE_SCHEMA = ? AND ROUTINE_TYPE = ? ORDER BY ROUTINE_NAME", d.dbName, routineType)
+		if err != nil {
+			return fmt.Errorf("failed to list routines: %v", err)
+		}
+
+		column := "Create Function"
+		if routineType == "PROCEDURE" {
+			column = "Create Procedure"
+		}
+
+		for _, name := range names {
+			row, err := d.showCreate("SHOW CREATE " + routineType + " " + quoteIdentifier(d.dbName) + "." + quoteIdentifier(name))
+			if err != nil {
+				return fmt.Errorf("failed to read routine %s: %v", name, err)
+			}
+			if !row[column].Valid {
+				return fmt.Errorf("no permission to read the body of routine %s", name)
+			}
+			d.writeRoutine("DROP "+routineType+" IF EXISTS "+quoteIdentifier(name), row["sql_mode"].String, row[column])
+		}
+	}
+	return nil
+}
+
+func (d *nativeDumper) writeEvents() error {
+	names, err := d.names("SELECT EVENT_NAME FROM information_schema.EVENTS WHERE EVENT_SCHEMA = ? ORDER BY EVENT_NAME", d.dbName)
+	if err != nil {
+		return fmt.Errorf("failed to list events: %v", err)
+	}
+
+	for _, name := range names {
+		row, err := d.showCreate("SHOW CREATE EVENT " + quoteIdentifier(d.dbName) + "." + quoteIdentifier(name))
+		if err != nil {
+			return fmt.Errorf("failed to read event %s: %v", name, err)
+		}
+		d.writeRoutine("DROP EVENT IF EXISTS "+quoteIdentifier(name), row["sql_mode"].String, row["Create Event"])
+	}
+	return nil
+}
+
+// writeRoutine writes a stored program under the sql_mode it was created
+// with. The body may contain semicolons, so the statement is terminated with
+// a custom delimiter.
+func (d *nativeDumper) writeRoutine(drop, sqlMode string, definition sql.NullString) {
+	fmt.Fprintf(d.out, "%s;\n", drop)
+	fmt.Fprintf(d.out, "/*!50003 SET sql_mode = %s */;\n", quoteString(sqlMode))
+	d.out.WriteString("DELIMITER ;;\n")
+	fmt.Fprintf(d.out, "%s ;;\n", definition.String)
+	d.out.WriteString("DELIMITER ;\n")
+	d.out.WriteString("/*!50003 SET sql_mode = 'NO_AUTO_VALUE_ON_ZERO' */;\n\n")
+}
+
+// showCreate runs one of the SHOW CREATE statements, which return a single
+// row for objects that exist.
+func (d *nativeDumper) showCreate(query string) (map[string]sql.NullString, error) {
+	row, err := queryRow(d.ctx, d.conn, query)
+	if err == nil && row == nil {
+		err = fmt.Errorf("no definition returned")
+	}
+	return row, err
+}
+
+func (d *nativeDumper) names(query string, args ...any) ([]string, error) {
+	rows, err := d.conn.QueryContext(d.ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// writeValue writes value as an SQL literal. Numbers are written as returned
+// by the server, binary data as hex literals and everything else as a quoted
+// string.
+func writeValue(b *strings.Builder, value sql.RawBytes, typeName string) {
+	if value == nil {
+		b.WriteString("NULL")
+		return
+	}
+
+	switch strings.TrimPrefix(typeName, "UNSIGNED ") {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "BIGINT", "DECIMAL", "FLOAT", "DOUBLE", "YEAR":
+		b.Write(value)
+	case "BIT", "BINARY", "VARBINARY", "TINYBLOB", "BLOB", "MEDIUMBLOB", "LONGBLOB", "GEOMETRY", "VECTOR":
+		if len(value) == 0 {
+			b.WriteString("''")
+			return
+		}
+		b.WriteString("0x")
+		b.WriteString(hex.EncodeToString(value))
+	default:
+		b.WriteString(quoteString(string(value)))
+	}
+}
+
+var stringEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"'", `\'`,
+	"\x00", `\0`,
+	"\n", `\n`,
+	"\r", `\r`,
+	"\x1a", `\Z`,
+)
+
+// quoteString quotes s as an SQL string literal.
+func quoteString(s string) string {
+	return "'" + stringEscaper.Replace(s) + "'"
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package mysql
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/lucasberto/database-backup-tool/internal/database"
+	"github.com/lucasberto/database-backup-tool/internal/executor"
+	"github.com/vbauerster/mpb/v8"
+)
+
+// Checkpoints holds the values xtrabackup and mariabackup write to
+// xtrabackup_checkpoints at the end of a backup.
+type Checkpoints struct {
+	BackupType string
+	FromLSN    string
+	ToLSN      string
+	LastLSN    string
+}
+
+// PhysicalBackup streams an xbstream archive of the whole instance, taken
+// with xtrabackup or mariabackup, gzip-compressed into w. The checkpoints are
+// written to a temporary directory on the remote host and returned so the
+// caller can record the LSN range of the backup.
+func (m *MySQL) PhysicalBackup(host executor.Executor, w io.Writer, progress *mpb.Progress) (*Checkpoints, error) {
+	if err := m.writeConfigFile(host); err != nil {
+		return nil, err
+	}
+
+	lsnDir, err := database.Run(host, "umask 077 && mktemp -d /tmp/xtrabackup.XXXXXXXXXX")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create checkpoint directory: %v", err)
+	}
+	defer database.RemoveFiles(host, lsnDir)
+
+	options := m.config.Physical
+	tool := options.Tool
+	if tool == "" {
+		tool = "xtrabackup"
+	}
+
+	args := []string{
+		tool,
+		"--defaults-extra-file=" + database.ShellQuote(m.configPath),
+		"--backup",
+		"--stream=xbstream",
+		"--target-dir=" + database.ShellQuote(lsnDir),
+		"--extra-lsndir=" + database.ShellQuote(lsnDir),
+	}
+	if options.Parallel > 0 {
+		args = append(args, fmt.Sprintf("--parallel=%d", options.Parallel))
+	}
+
+	if err := database.Stream(host, "Physical backup", strings.Join(args, " "), w, progress); err != nil {
+		return nil, fmt.Errorf("%s failed: %v", tool, err)
+	}
+
+	output, err := database.Run(host, "cat "+database.ShellQuote(lsnDir+"/xtrabackup_checkpoints"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoints: %v", err)
+	}
+
+	return parseCheckpoints(output), nil
+}
+
+func parseCheckpoints(data string) *Checkpoints {
+	checkpoints := &Checkpoints{}
+	for _, line := range strings.Split(data, "\n") {
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "backup_type":
+			checkpoints.BackupType = value
+		case "from_lsn":
+			checkpoints.FromLSN = value
+		case "to_lsn":
+			checkpoints.ToLSN = value
+		case "last_lsn":
+			checkpoints.LastLSN = value
+		}
+	}
+	return checkpoints
+}
@@ -0,0 +1,130 @@
+package redis
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/lucasberto/database-backup-tool/internal/config"
+	"github.com/lucasberto/database-backup-tool/internal/database"
+	"github.com/lucasberto/database-backup-tool/internal/executor"
+	"github.com/lucasberto/database-backup-tool/internal/storage"
+	"github.com/vbauerster/mpb/v8"
+)
+
+const (
+	defaultPort = 6379
+
+	// snapshotName is used as the database name of the single snapshot
+	// taken per instance, since Redis databases are numbered and all of them
+	// are part of the same RDB file.
+	snapshotName = "rdb"
+
+	// saveTimeout is how many seconds to wait for BGSAVE to finish.
+	saveTimeout = 3600
+)
+
+type Redis struct {
+	config   config.Database
+	authPath string
+}
+
+func New(dbConfig config.Database) *Redis {
+	return &Redis{config: dbConfig}
+}
+
+// Prepare writes the password to a temporary file on the remote host. It is
+// loaded into REDISCLI_AUTH by the commands that need it, so it never shows
+// up on a command line.
+func (r *Redis) Prepare(host executor.Executor) error {
+	if r.config.Password == "" {
+		return nil
+	}
+
+	authPath, err := database.WriteTempFile(host, "redisauth", r.config.Password)
+	if err != nil {
+		return fmt.Errorf("failed to create auth file: %v", err)
+	}
+	r.authPath = authPath
+	return nil
+}
+
+// Cleanup removes the file written by Prepare.
+func (r *Redis) Cleanup(host executor.Executor) error {
+	if err := database.RemoveFiles(host, r.authPath); err != nil {
+		return err
+	}
+	r.authPath = ""
+	return nil
+}
+
+func (r *Redis) Extension() string {
+	return ".rdb.gz"
+}
+
+// ListDatabases returns a single entry, as the whole instance is backed up
+// as one snapshot.
+func (r *Redis) ListDatabases(host executor.Executor) ([]string, error) {
+	return []string{snapshotName}, nil
+}
+
+// Dump triggers BGSAVE, waits until LASTSAVE changes and then streams the
+// resulting RDB file into w. The file location is read with CONFIG GET unless
+// rdb_path is configured.
+func (r *Redis) Dump(host executor.Executor, dbName string, w io.Writer, progress *mpb.Progress, manifest *storage.Manifest) error {
+	if err := database.Stream(host, "Dumping RDB snapshot", r.dumpScript(), w, progress); err != nil {
+		return fmt.Errorf("redis snapshot failed: %v", err)
+	}
+	return nil
+}
+
+func (r *Redis) dumpScript() string {
+	cli := []string{"redis-cli", "-h", "127.0.0.1", "-p", strconv.Itoa(r.port())}
+	if r.config.User != "" {
+		cli = append(cli, "--user", database.ShellQuote(r.config.User))
+	}
+
+	var script strings.Builder
+	if r.authPath != "" {
+		fmt.Fprintf(&script, "REDISCLI_AUTH=$(cat %s) || exit 1; export REDISCLI_AUTH; ", database.ShellQuote(r.authPath))
+	}
+	fmt.Fprintf(&script, `cli() { %s "$@"; }; `, strings.Join(cli, " "))
+
+	// The save writes nothing to stdout, so it is staged with a heartbeat.
+	var save strings.Builder
+	// A save that is already running finished before our request, so its
+	// LASTSAVE must not be mistaken for ours.
+	fmt.Fprintf(&save, `i=0; while cli INFO persistence | grep -q '^rdb_bgsave_in_progress:1'; do i=$((i+1)); [ $i -ge %d ] && { echo "timed out waiting for a running BGSAVE" >&2; exit 1; }; sleep 1; done; `, saveTimeout)
+	// LASTSAVE only has one-second resolution, so the request is made in a
+	// later second than the previous save. A save finished at or after that
+	// second, with none running, is then ours or one started after it.
+	save.WriteString(`now() { cli TIME | head -n 1; }; start=$(now) && last=$(cli LASTSAVE) || exit 1; `)
+	save.WriteString(`case "$start$last" in ''|*[!0-9]*) echo "unexpected reply to TIME or LASTSAVE" >&2; exit 1 ;; esac; `)
+	save.WriteString(`while [ "$start" -le "$last" ]; do sleep 1; start=$(now) || exit 1; done; `)
+	save.WriteString(`case $(cli BGSAVE SCHEDULE) in *started*|*scheduled*|*"already in progress"*) ;; *) echo "BGSAVE was refused" >&2; exit 1 ;; esac; `)
+	fmt.Fprintf(&save, `i=0; until cli INFO persistence | grep -q '^rdb_bgsave_in_progress:0' && [ "$(cli LASTSAVE)" -ge "$start" ]; do i=$((i+1)); [ $i -ge %d ] && { echo "timed out waiting for BGSAVE" >&2; exit 1; }; sleep 1; done; `, saveTimeout)
+	save.WriteString(`cli INFO persistence | grep -q '^rdb_last_bgsave_status:ok' || { echo "BGSAVE failed" >&2; exit 1; }; `)
+
+	script.WriteString(database.Staged(save.String()))
+
+	if r.config.RDBPath != "" {
+		fmt.Fprintf(&script, "cat %s", database.ShellQuote(r.config.RDBPath))
+	} else {
+		script.WriteString(`dir=$(cli CONFIG GET dir | sed -n 2p) && file=$(cli CONFIG GET dbfilename | sed -n 2p) && cat "$dir/$file"`)
+	}
+	return script.String()
+}
+
+// Restore is not supported: loading an RDB file means stopping Redis,
+// replacing the file and starting it again.
+func (r *Redis) Restore(host executor.Executor, source, target string, reader io.Reader, progress *mpb.Progress) error {
+	return fmt.Errorf("redis snapshots have to be restored manually: stop redis, replace its RDB file with the decompressed backup and start it again")
+}
+
+func (r *Redis) port() int {
+	if r.config.Port == 0 {
+		return defaultPort
+	}
+	return r.config.Port
+}
@@ -0,0 +1,90 @@
+package redis
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lucasberto/database-backup-tool/internal/config"
+	"github.com/lucasberto/database-backup-tool/internal/executor"
+)
+
+// fakeRedisCLI keeps LASTSAVE and a running BGSAVE in $STATE. A BGSAVE
+// finishes $SAVE_SECONDS after it was requested.
+const fakeRedisCLI = `#!/bin/sh
+while [ "${1#-}" != "$1" ]; do shift 2; done
+now=$(date +%s)
+if [ -f "$STATE/running" ] && [ "$(cat "$STATE/running")" -le "$now" ]; then
+	mv "$STATE/running" "$STATE/lastsave"
+	echo dump > "$STATE/dump.rdb"
+fi
+case "$1" in
+TIME) echo "$now"; echo 0 ;;
+LASTSAVE) cat "$STATE/lastsave" ;;
+BGSAVE)
+	if [ -f "$STATE/running" ]; then echo "ERR Background save already in progress"; exit 0; fi
+	echo $((now + SAVE_SECONDS)) > "$STATE/running"
+	echo "Background saving started" ;;
+INFO)
+	if [ -f "$STATE/running" ]; then echo "rdb_bgsave_in_progress:1"; else echo "rdb_bgsave_in_progress:0"; fi
+	echo "rdb_last_bgsave_status:ok" ;;
+CONFIG) if [ "$3" = dir ]; then printf 'dir\n%s\n' "$STATE"; else printf 'dbfilename\ndump.rdb\n'; fi ;;
+esac
+`
+
+func TestDumpScriptWaitsForItsOwnSave(t *testing.T) {
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "redis-cli"), []byte(fakeRedisCLI), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	tests := []struct {
+		name        string
+		saveSeconds int
+		running     bool
+	}{
+		{name: "save in the same second as the previous one"},
+		{name: "slow save", saveSeconds: 2},
+		{name: "save already running", saveSeconds: 1, running: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := t.TempDir()
+			t.Setenv("STATE", state)
+			t.Setenv("SAVE_SECONDS", strconv.Itoa(tt.saveSeconds))
+
+			now := time.Now().Unix()
+			write := func(name string, value int64) {
+				if err := os.WriteFile(filepath.Join(state, name), []byte(strconv.FormatInt(value, 10)+"\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			write("lastsave", now)
+			if tt.running {
+				write("running", now+1)
+			}
+
+			r := New(config.Database{})
+			var stdout, stderr bytes.Buffer
+			done := make(chan error, 1)
+			go func() { done <- executor.NewLocal().Run(r.dumpScript(), nil, &stdout, &stderr) }()
+
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Fatalf("dump failed: %v: %s", err, stderr.String())
+				}
+			case <-time.After(15 * time.Second):
+				t.Fatal("dump did not notice the save finishing")
+			}
+			if strings.TrimSpace(stdout.String()) != "dump" {
+				t.Errorf("got %q, want the RDB file", stdout.String())
+			}
+		})
+	}
+}
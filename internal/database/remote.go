@@ -0,0 +1,191 @@
+package database
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/lucasberto/database-backup-tool/internal/executor"
+	"github.com/vbauerster/mpb/v8"
+	"github.com/vbauerster/mpb/v8/decor"
+)
+
+// Run executes cmd on host and returns its trimmed output.
+func Run(host executor.Executor, cmd string) (string, error) {
+	return RunWithInput(host, cmd, nil)
+}
+
+// RunWithInput executes cmd on the remote host with stdin as its input and
+// returns its trimmed output. Secrets should be passed this way so they never
+// show up in the remote process list.
+func RunWithInput(host executor.Executor, cmd string, stdin io.Reader) (string, error) {
+	var stdout strings.Builder
+	stderr := &tailBuffer{limit: 4096}
+
+	if err := host.Run(cmd, stdin, &stdout, stderr); err != nil {
+		return "", fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// WriteTempFile stores contents in a new file only readable by the remote
+// user and returns its path. The contents are sent over stdin, and the file
+// is removed again if writing fails.
+func WriteTempFile(host executor.Executor, prefix, contents string) (string, error) {
+	cmd := fmt.Sprintf(`umask 077 && f=$(mktemp %s) && { cat > "$f" || { rm -f "$f"; exit 1; }; } && echo "$f"`,
+		ShellQuote("/tmp/"+prefix+".XXXXXXXXXX"),
+	)
+
+	path, err := RunWithInput(host, cmd, strings.NewReader(contents))
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %v", err)
+	}
+	if path == "" {
+		return "", fmt.Errorf("failed to create temporary file: mktemp returned no path")
+	}
+	return path, nil
+}
+
+// RemoveFiles deletes paths on the remote host. Empty paths are ignored.
+func RemoveFiles(host executor.Executor, paths ...string) error {
+	var quoted []string
+	for _, path := range paths {
+		if path != "" {
+			quoted = append(quoted, ShellQuote(path))
+		}
+	}
+	if len(quoted) == 0 {
+		return nil
+	}
+
+	if _, err := Run(host, "rm -rf "+strings.Join(quoted, " ")); err != nil {
+		return fmt.Errorf("failed to remove %s: %v", strings.Join(paths, ", "), err)
+	}
+	return nil
+}
+
+// Stream runs cmd on the remote host and writes its gzip-compressed output
+// into w while showing the transferred size under label. The uncompressed
+// output is also copied to taps.
+func Stream(host executor.Executor, label, cmd string, w io.Writer, progress *mpb.Progress, taps ...io.Writer) error {
+	return stream(host, label, cmd, w, progress, true, taps)
+}
+
+// StreamRaw is like Stream for commands whose output is already compressed.
+func StreamRaw(host executor.Executor, label, cmd string, w io.Writer, progress *mpb.Progress, taps ...io.Writer) error {
+	return stream(host, label, cmd, w, progress, false, taps)
+}
+
+func stream(host executor.Executor, label, cmd string, w io.Writer, progress *mpb.Progress, compress bool, taps []io.Writer) error {
+	bar := NewBar(progress, label)
+
+	var out io.WriteCloser
+	if compress {
+		out = NewCompressedProgressWriter(w, bar)
+	} else {
+		out = &ProgressWriter{Writer: w, Bar: bar}
+	}
+	defer out.Close()
+
+	stdout := io.MultiWriter(append([]io.Writer{out}, taps...)...)
+	stderr := &tailBuffer{limit: 4096}
+
+	if err := host.Run(cmd, nil, stdout, stderr); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return out.Close()
+}
+
+// Feed decompresses the gzip stream read from r into the stdin of cmd on the
+// remote host while showing the amount of compressed data read under label.
+func Feed(host executor.Executor, label, cmd string, r io.Reader, progress *mpb.Progress) error {
+	return feed(host, label, cmd, r, progress, true)
+}
+
+// FeedRaw is like Feed for commands that read the compressed stream as is.
+func FeedRaw(host executor.Executor, label, cmd string, r io.Reader, progress *mpb.Progress) error {
+	return feed(host, label, cmd, r, progress, false)
+}
+
+func feed(host executor.Executor, label, cmd string, r io.Reader, progress *mpb.Progress, decompress bool) error {
+	bar := NewBar(progress, label)
+	pw := &ProgressWriter{Writer: io.Discard, Bar: bar}
+	defer pw.Close()
+
+	in := io.TeeReader(r, pw)
+	if decompress {
+		gz, err := gzip.NewReader(in)
+		if err != nil {
+			return fmt.Errorf("failed to read backup: %v", err)
+		}
+		defer gz.Close()
+		in = gz
+	}
+
+	stderr := &tailBuffer{limit: 4096}
+
+	if err := host.Run(cmd, in, nil, stderr); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// ShellQuote wraps s in single quotes for safe use in a POSIX shell command.
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// NewBar adds a progress bar of unknown total showing the transferred size
+// under label.
+func NewBar(progress *mpb.Progress, label string) *mpb.Bar {
+	if len(label) > 40 {
+		label = label[:37] + "..."
+	}
+
+	return progress.New(-1,
+		mpb.BarStyle(),
+		mpb.BarRemoveOnComplete(),
+		mpb.PrependDecorators(
+			decor.Name(label+" ", decor.WC{W: 45, C: decor.DindentRight}),
+			decor.CurrentKibiByte("%.2f"),
+		),
+	)
+}
+
+// heartbeat is written to stderr by Staged while a command has no output
+// yet, so stall detection can tell it is still working.
+const heartbeat = "# still working"
+
+// Staged returns a shell snippet running stage, a step that prepares the
+// output of a command without writing any, with a heartbeat on stderr every
+// few seconds. stage runs in a subshell; when it fails the command exits with
+// its status.
+func Staged(stage string) string {
+	return `( while sleep 5 </dev/null >/dev/null 2>&1; do echo '` + heartbeat + `' >&2 || exit; done ) </dev/null >/dev/null & hb=$!; ` +
+		`( ` + stage + ` ); s=$?; kill $hb 2>/dev/null; [ $s -eq 0 ] || exit $s; `
+}
+
+// tailBuffer keeps only the last limit bytes written to it, so the stderr of
+// verbose tools such as xtrabackup cannot grow without bound. Heartbeats are
+// left out.
+type tailBuffer struct {
+	limit int
+	data  []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	p = bytes.ReplaceAll(p, []byte(heartbeat+"\n"), nil)
+	b.data = append(b.data, p...)
+	if len(b.data) > b.limit {
+		b.data = append([]byte(nil), b.data[len(b.data)-b.limit:]...)
+	}
+	return n, nil
+}
+
+func (b *tailBuffer) String() string {
+	return string(b.data)
+}
@@ -0,0 +1,58 @@
+package database
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lucasberto/database-backup-tool/internal/executor"
+)
+
+func TestStaged(t *testing.T) {
+	tests := []struct {
+		name       string
+		stage      string
+		wantOutput string
+		wantErr    string
+		heartbeat  bool
+	}{
+		{name: "quick stage", stage: `x=1`, wantOutput: "done\n"},
+		{name: "slow stage", stage: `sleep 6`, wantOutput: "done\n", heartbeat: true},
+		{name: "failing stage", stage: `echo "no space left" >&2; exit 3`, wantErr: "exit status 3: no space left"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			tail := &tailBuffer{limit: 4096}
+			start := time.Now()
+			err := executor.NewLocal().Run(Staged(tt.stage)+`echo done`, nil, &stdout, io.MultiWriter(tail, &stderr))
+			if elapsed := time.Since(start); elapsed > 9*time.Second {
+				t.Errorf("took %s, the heartbeat was not stopped", elapsed)
+			}
+
+			if tt.wantErr != "" {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				if got := err.Error() + ": " + strings.TrimSpace(tail.String()); got != tt.wantErr {
+					t.Errorf("got %q, want %q", got, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Run: %v: %s", err, stderr.String())
+			}
+			if stdout.String() != tt.wantOutput {
+				t.Errorf("got output %q, want %q", stdout.String(), tt.wantOutput)
+			}
+			if got := strings.Contains(stderr.String(), heartbeat); got != tt.heartbeat {
+				t.Errorf("heartbeat on stderr: %v, want %v", got, tt.heartbeat)
+			}
+			if strings.Contains(tail.String(), heartbeat) {
+				t.Errorf("heartbeat kept in error output %q", tail.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package sqlite
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/lucasberto/database-backup-tool/internal/config"
+	"github.com/lucasberto/database-backup-tool/internal/database"
+	"github.com/lucasberto/database-backup-tool/internal/executor"
+	"github.com/lucasberto/database-backup-tool/internal/storage"
+	"github.com/vbauerster/mpb/v8"
+)
+
+// SQLite backs up database files on the remote host. Database names are the
+// remote paths of the files.
+type SQLite struct {
+	config config.Database
+}
+
+func New(dbConfig config.Database) *SQLite {
+	return &SQLite{config: dbConfig}
+}
+
+// Prepare does nothing, as SQLite needs no credentials.
+func (s *SQLite) Prepare(host executor.Executor) error {
+	return nil
+}
+
+// Cleanup does nothing, as Prepare leaves nothing behind.
+func (s *SQLite) Cleanup(host executor.Executor) error {
+	return nil
+}
+
+func (s *SQLite) Extension() string {
+	return ".sqlite.gz"
+}
+
+// ListDatabases is not supported, since there is no server to ask. The
+// database files have to be listed with name or databases.
+func (s *SQLite) ListDatabases(host executor.Executor) ([]string, error) {
+	return nil, fmt.Errorf("sqlite databases cannot be discovered, set name or databases to their paths")
+}
+
+// Dump takes a consistent copy of the database file at dbName with the
+// online backup API, checks its integrity and streams it into w. The copy is
+// made in a temporary directory that is always removed.
+func (s *SQLite) Dump(host executor.Executor, dbName string, w io.Writer, progress *mpb.Progress, manifest *storage.Manifest) error {
+	path := database.ShellQuote(dbName)
+	cmd := fmt.Sprintf(`[ -f %s ] || { echo "no such file: "%s >&2; exit 1; }; `, path, path) +
+		`d=$(mktemp -d /tmp/sqlite.XXXXXXXXXX) || exit 1; trap 'rm -rf "$d"' EXIT; ` +
+		database.Staged(fmt.Sprintf(`sqlite3 %s ".backup $d/snapshot.db" || exit 1; `, path)+
+			`r=$(sqlite3 "$d/snapshot.db" 'PRAGMA integrity_check;') || exit 1; `+
+			`[ "$r" = ok ] || { echo "integrity check failed: $r" >&2; exit 1; }`) +
+		`cat "$d/snapshot.db"`
+
+	if err := database.Stream(host, "Dumping "+dbName, cmd, w, progress); err != nil {
+		return fmt.Errorf("sqlite backup failed: %v", err)
+	}
+	return nil
+}
+
+// Restore uploads the backup to a temporary file and loads it into the
+// database file at target with .restore, which takes the same locks as any
+// other writer instead of replacing the file underneath open connections.
+func (s *SQLite) Restore(host executor.Executor, source, target string, r io.Reader, progress *mpb.Progress) error {
+	cmd := `t=$(mktemp /tmp/sqlite-restore.XXXXXXXXXX) || exit 1; trap 'rm -f "$t"' EXIT; cat > "$t" && ` +
+		fmt.Sprintf(`sqlite3 %s ".restore $t"`, database.ShellQuote(target))
+
+	if err := database.Feed(host, "Restoring "+target, cmd, r, progress); err != nil {
+		return fmt.Errorf("sqlite restore failed: %v", err)
+	}
+	return nil
+}
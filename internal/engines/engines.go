@@ -0,0 +1,29 @@
+package engines
+
+import (
+	"fmt"
+
+	"github.com/lucasberto/database-backup-tool/internal/config"
+	"github.com/lucasberto/database-backup-tool/internal/database"
+	"github.com/lucasberto/database-backup-tool/internal/database/mongodb"
+	"github.com/lucasberto/database-backup-tool/internal/database/mysql"
+	"github.com/lucasberto/database-backup-tool/internal/database/redis"
+	"github.com/lucasberto/database-backup-tool/internal/database/sqlite"
+)
+
+// New returns the engine for the database type of dbConfig. MySQL is used
+// when no type is set.
+func New(dbConfig config.Database) (database.Engine, error) {
+	switch dbConfig.EngineType() {
+	case config.TypeMySQL:
+		return mysql.New(dbConfig), nil
+	case config.TypeMongoDB:
+		return mongodb.New(dbConfig), nil
+	case config.TypeRedis:
+		return redis.New(dbConfig), nil
+	case config.TypeSQLite:
+		return sqlite.New(dbConfig), nil
+	default:
+		return nil, fmt.Errorf("unsupported database type: %s", dbConfig.Type)
+	}
+}
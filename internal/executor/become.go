@@ -0,0 +1,75 @@
+package executor
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+// askpassScript reads the sudo password from the first line of stdin in a
+// wrapper shell and hands it to sudo through an askpass helper, so the line
+// is consumed whether or not sudo asks for it and never reaches the command.
+// The helper only holds a reference to the environment, not the password.
+const askpassScript = `IFS= read -r p || exit 1
+a=$(mktemp) || exit 1
+trap 'rm -f "$a"' EXIT
+printf '%s\n' '#!/bin/sh' 'printf "%s\\n" "$BECOME_PASSWORD"' > "$a" && chmod 700 "$a" || exit 1
+BECOME_PASSWORD=$p SUDO_ASKPASS=$a `
+
+// Become runs commands on another executor through sudo or doas, optionally
+// as a different user. A sudo password is sent as the first line of stdin and
+// read by a wrapper shell, so it never appears in the command line or in the
+// command's own input.
+type Become struct {
+	host     Executor
+	program  string
+	user     string
+	password string
+}
+
+func NewBecome(host Executor, program, user, password string) *Become {
+	return &Become{host: host, program: program, user: user, password: password}
+}
+
+func (b *Become) Run(cmd string, stdin io.Reader, stdout, stderr io.Writer) error {
+	args := []string{b.program}
+	switch {
+	case b.program == "sudo" && b.password != "":
+		args = append(args, "-A", "-p", "''")
+	case b.password != "":
+		return fmt.Errorf("%s cannot read a password from stdin, configure it to run without one", b.program)
+	default:
+		args = append(args, "-n")
+	}
+	if b.user != "" {
+		args = append(args, "-u", shellQuote(b.user))
+	}
+	args = append(args, "sh", "-c", shellQuote(cmd))
+	command := strings.Join(args, " ")
+
+	if b.password != "" {
+		command = "sh -c " + shellQuote(askpassScript+command)
+		password := strings.NewReader(b.password + "\n")
+		if stdin == nil {
+			stdin = password
+		} else {
+			stdin = io.MultiReader(password, stdin)
+		}
+	}
+	return b.host.Run(command, stdin, stdout, stderr)
+}
+
+// Dial forwards to the underlying executor, as connections are not affected
+// by the user commands run as.
+func (b *Become) Dial(network, addr string) (net.Conn, error) {
+	dialer, ok := b.host.(Dialer)
+	if !ok {
+		return nil, fmt.Errorf("transport does not support forwarding connections")
+	}
+	return dialer.Dial(network, addr)
+}
+
+func (b *Become) Close() error {
+	return b.host.Close()
+}
@@ -0,0 +1,70 @@
+package executor
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeSudo asks the askpass helper for the password unless FAKE_NOPASSWD is
+// set, then runs the command like sudo would.
+const fakeSudo = `#!/bin/sh
+askpass=false
+while [ $# -gt 0 ]; do
+	case "$1" in
+	-A) askpass=true; shift ;;
+	-n) shift ;;
+	-p|-u) shift 2 ;;
+	*) break ;;
+	esac
+done
+if $askpass && [ -z "$FAKE_NOPASSWD" ]; then
+	[ "$("$SUDO_ASKPASS")" = "secret" ] || { echo "sudo: incorrect password" >&2; exit 1; }
+fi
+unset BECOME_PASSWORD
+exec "$@"
+`
+
+func TestBecomeKeepsPasswordOutOfStdin(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "sudo"), []byte(fakeSudo), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	tests := []struct {
+		name     string
+		password string
+		nopasswd bool
+		wantErr  bool
+	}{
+		{name: "password", password: "secret"},
+		{name: "nopasswd with password set", password: "secret", nopasswd: true},
+		{name: "no password", nopasswd: true},
+		{name: "wrong password", password: "wrong", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.nopasswd {
+				t.Setenv("FAKE_NOPASSWD", "1")
+			}
+			b := NewBecome(NewLocal(), "sudo", "backup", tt.password)
+			var stdout, stderr bytes.Buffer
+			err := b.Run(`cat; echo "env:$BECOME_PASSWORD"`, strings.NewReader("input\n"), &stdout, &stderr)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got output %q", stdout.String())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Run: %v (%s)", err, stderr.String())
+			}
+			if got := stdout.String(); got != "input\nenv:\n" {
+				t.Errorf("command saw %q, want only its own input", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lucasberto/database-backup-tool/internal/config"
+	"github.com/lucasberto/database-backup-tool/internal/ssh"
+)
+
+const (
+	defaultConnectTimeout    = 30 * time.Second
+	defaultKeepaliveInterval = 30 * time.Second
+
+	// sshd allows 10 sessions per connection unless MaxSessions says otherwise.
+	defaultSessionsPerConnection = 10
+)
+
+// Connect returns the executor for server: a Local one for the local
+// transport, otherwise a pool of SSH connections. With become set, commands
+// are run through sudo or doas.
+func Connect(server config.Server) (Executor, error) {
+	host, err := connect(server)
+	if err != nil || server.Become == "" {
+		return host, err
+	}
+
+	program, user, err := config.ParseBecome(server.Become)
+	if err != nil {
+		host.Close()
+		return nil, err
+	}
+	return NewBecome(host, program, user, server.BecomePassword), nil
+}
+
+func connect(server config.Server) (Executor, error) {
+	if server.Transport == config.TransportLocal {
+		return NewLocal(), nil
+	}
+
+	sessions := server.SessionsPerConnection
+	if sessions == 0 {
+		sessions = defaultSessionsPerConnection
+	}
+	return ssh.NewPool(sessions, func() (*ssh.Client, error) {
+		return dial(server)
+	})
+}
+
+// dial opens a new SSH connection to the server.
+func dial(server config.Server) (*ssh.Client, error) {
+	var client *ssh.Client
+	var err error
+	switch {
+	case server.AuthType == "key" && server.PrivateKey != "":
+		client, err = ssh.NewClientWithKey(server.Host, server.Port, server.User, []byte(server.PrivateKey), []byte(server.Certificate), server.Passphrase)
+	case server.AuthType == "password":
+		client, err = ssh.NewClient(server.Host, server.Port, server.User, server.AuthType, server.Password, server.Passphrase)
+	default:
+		client, err = ssh.NewClient(server.Host, server.Port, server.User, server.AuthType, server.KeyPath, server.Passphrase)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	client.Timeout = seconds(server.ConnectTimeout, defaultConnectTimeout)
+	client.KeepaliveInterval = seconds(server.KeepaliveInterval, defaultKeepaliveInterval)
+	client.StallTimeout = seconds(server.StallTimeout, 0)
+
+	if err := client.VerifyHostKeys(server.KnownHosts, server.HostCA); err != nil {
+		client.Close()
+		return nil, err
+	}
+	if server.ProxyJump != "" {
+		err = connectThrough(client, server)
+	} else {
+		err = client.Connect()
+	}
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// connectThrough connects client through the jump hosts of the server, one
+// hop at a time. Jump hosts authenticate with the IdentityFile ssh_config
+// gives for them, or else with the agent, never with the server's
+// credentials.
+func connectThrough(client *ssh.Client, server config.Server) error {
+	hops, err := config.ParseProxyJump(server.ProxyJump)
+	if err != nil {
+		return err
+	}
+
+	var previous *ssh.Client
+	for _, hop := range hops {
+		host, user, port := hop.Host, hop.User, hop.Port
+		identity := ""
+		if server.SSHConfig {
+			resolved, err := config.LookupSSHHost(hop.Host)
+			if err != nil {
+				return err
+			}
+			host = resolved.HostName
+			if user == "" {
+				user = resolved.User
+			}
+			if port == 0 {
+				port = resolved.Port
+			}
+			if len(resolved.IdentityFiles) > 0 {
+				identity = resolved.IdentityFiles[0]
+			}
+		}
+		if user == "" {
+			user = server.User
+		}
+		if port == 0 {
+			port = 22
+		}
+
+		jump, err := dialJump(client, host, port, user, identity, previous)
+		if err != nil {
+			if previous != nil {
+				previous.Close()
+			}
+			return fmt.Errorf("jump host %s: %v", hop.Host, err)
+		}
+		previous = jump
+	}
+
+	if err := client.ConnectThrough(previous); err != nil {
+		previous.Close()
+		return err
+	}
+	return nil
+}
+
+// dialJump connects to one jump host, directly or through the previous one.
+// Like ssh, it falls back to the agent for an identity file protected by a
+// passphrase, as the key is then usually loaded there.
+func dialJump(client *ssh.Client, host string, port int, user, identity string, previous *ssh.Client) (*ssh.Client, error) {
+	var jump *ssh.Client
+	var err error
+	if identity != "" {
+		jump, err = ssh.NewClient(host, port, user, "key", identity, "")
+	}
+	if identity == "" || errors.Is(err, ssh.ErrPassphraseMissing) {
+		jump, err = ssh.NewClient(host, port, user, "agent", "", "")
+		if err != nil && identity != "" {
+			err = fmt.Errorf("%s is encrypted and no agent is available: %v", identity, err)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	client.PrepareJump(jump)
+	if previous == nil {
+		err = jump.Connect()
+	} else {
+		err = jump.ConnectThrough(previous)
+	}
+	if err != nil {
+		jump.Close()
+		return nil, err
+	}
+	return jump, nil
+}
+
+// seconds converts a timeout setting, using fallback when it is unset.
+func seconds(value int, fallback time.Duration) time.Duration {
+	if value == 0 {
+		return fallback
+	}
+	return time.Duration(value) * time.Second
+}
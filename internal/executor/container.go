@@ -0,0 +1,72 @@
+package executor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Container runs commands inside a running container with docker exec or
+// podman exec on another executor. Closing it leaves the host open.
+type Container struct {
+	host    Executor
+	runtime string
+	name    string
+}
+
+func NewContainer(host Executor, runtime, name string) *Container {
+	return &Container{host: host, runtime: runtime, name: name}
+}
+
+func (c *Container) Run(cmd string, stdin io.Reader, stdout, stderr io.Writer) error {
+	wrapped := fmt.Sprintf("%s exec -i %s sh -c %s", c.runtime, shellQuote(c.name), shellQuote(cmd))
+	return c.host.Run(wrapped, stdin, stdout, stderr)
+}
+
+func (c *Container) Close() error {
+	return nil
+}
+
+// FindContainers returns the names of the running containers on host that
+// carry label and run image. Either selector may be empty. Images match by
+// repository, so "mysql" matches "docker.io/library/mysql:8.0".
+func FindContainers(host Executor, runtime, label, image string) ([]string, error) {
+	cmd := runtime + " ps --format '{{.Names}} {{.Image}}'"
+	if label != "" {
+		cmd += " --filter " + shellQuote("label="+label)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err := host.Run(cmd, nil, &stdout, &stderr); err != nil {
+		return nil, fmt.Errorf("failed to list containers: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var names []string
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if image != "" && !matchesImage(fields[1], image) {
+			continue
+		}
+		// Docker lists every name of a linked container.
+		names = append(names, strings.Split(fields[0], ",")[0])
+	}
+	return names, nil
+}
+
+func matchesImage(reference, image string) bool {
+	if i := strings.Index(reference, "@"); i >= 0 {
+		reference = reference[:i]
+	}
+	if i := strings.LastIndex(reference, ":"); i > strings.LastIndex(reference, "/") {
+		reference = reference[:i]
+	}
+	return reference == image || strings.HasSuffix(reference, "/"+image)
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,43 @@
+package executor
+
+import (
+	"io"
+	"net"
+	"os/exec"
+)
+
+// Executor runs shell commands on the host a database is reached from. The
+// SSH client runs them remotely, Local runs them on the backup host itself.
+type Executor interface {
+	Run(cmd string, stdin io.Reader, stdout, stderr io.Writer) error
+	Close() error
+}
+
+// Dialer is implemented by executors that can open TCP connections from the
+// host they run commands on, so database protocols can be spoken from Go.
+type Dialer interface {
+	Dial(network, addr string) (net.Conn, error)
+}
+
+// Local runs commands with sh on the machine running the backup.
+type Local struct{}
+
+func NewLocal() *Local {
+	return &Local{}
+}
+
+func (l *Local) Run(cmd string, stdin io.Reader, stdout, stderr io.Writer) error {
+	c := exec.Command("sh", "-c", cmd)
+	c.Stdin = stdin
+	c.Stdout = stdout
+	c.Stderr = stderr
+	return c.Run()
+}
+
+func (l *Local) Close() error {
+	return nil
+}
+
+func (l *Local) Dial(network, addr string) (net.Conn, error) {
+	return net.Dial(network, addr)
+}
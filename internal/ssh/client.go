@@ -1,18 +1,40 @@
 package ssh
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
 
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
 )
 
+// ErrPassphraseMissing is returned for an encrypted private key when no
+// passphrase was given.
+var ErrPassphraseMissing = errors.New("private key is encrypted, a passphrase is required")
+
 type Client struct {
 	sshClient  *ssh.Client
 	Config     *ssh.ClientConfig
 	Host       string
 	Port       int
 	Passphrase string
+	agentConn  net.Conn
+	jump       *Client
+	lost       atomic.Bool
+
+	// Timeout limits connecting and the SSH handshake. KeepaliveInterval
+	// is how often the connection is checked, and StallTimeout fails
+	// commands whose output stops for that long. Zero disables each.
+	Timeout           time.Duration
+	KeepaliveInterval time.Duration
+	StallTimeout      time.Duration
 }
 
 func NewClient(host string, port int, user string, authType string, authData string, passphrase string) (*Client, error) {
@@ -24,24 +46,88 @@ func NewClient(host string, port int, user string, authType string, authData str
 		if err != nil {
 			return nil, fmt.Errorf("unable to read private key: %v", err)
 		}
-
-		var signer ssh.Signer
-		if passphrase != "" {
-			signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(passphrase))
-		} else {
-			signer, err = ssh.ParsePrivateKey(key)
-		}
-		if err != nil {
-			return nil, fmt.Errorf("unable to parse private key: %v", err)
+		// Like OpenSSH, a certificate next to the key is used when present.
+		cert, err := os.ReadFile(authData + "-cert.pub")
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("unable to read certificate: %v", err)
 		}
-
-		auth = ssh.PublicKeys(signer)
+		return NewClientWithKey(host, port, user, key, cert, passphrase)
 	case "password":
 		auth = ssh.Password(authData)
+	case "agent":
+		return newAgentClient(host, port, user)
 	default:
 		return nil, fmt.Errorf("unsupported authentication type: %s", authType)
 	}
 
+	return newClient(host, port, user, auth), nil
+}
+
+// NewClientWithKey authenticates with private key material held in memory,
+// in PEM or OpenSSH format, so the key never has to be written to disk. An
+// OpenSSH user certificate for the key can be given in cert, or left nil.
+func NewClientWithKey(host string, port int, user string, key, cert []byte, passphrase string) (*Client, error) {
+	var signer ssh.Signer
+	var err error
+	if passphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(passphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey(key)
+	}
+	if _, ok := err.(*ssh.PassphraseMissingError); ok {
+		return nil, ErrPassphraseMissing
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse private key: %v", err)
+	}
+
+	if len(cert) == 0 {
+		return newClient(host, port, user, ssh.PublicKeys(signer)), nil
+	}
+
+	certSigner, err := newCertSigner(signer, cert)
+	if err != nil {
+		return nil, err
+	}
+	// The plain key is still offered in case the server does not trust the CA.
+	return newClient(host, port, user, ssh.PublicKeys(certSigner, signer)), nil
+}
+
+func newCertSigner(signer ssh.Signer, data []byte) (ssh.Signer, error) {
+	key, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse certificate: %v", err)
+	}
+	cert, ok := key.(*ssh.Certificate)
+	if !ok || cert.CertType != ssh.UserCert {
+		return nil, fmt.Errorf("certificate is not an SSH user certificate")
+	}
+
+	certSigner, err := ssh.NewCertSigner(cert, signer)
+	if err != nil {
+		return nil, fmt.Errorf("certificate does not match private key: %v", err)
+	}
+	return certSigner, nil
+}
+
+// newAgentClient authenticates with the keys and certificates held by the
+// agent listening on SSH_AUTH_SOCK.
+func newAgentClient(host string, port int, user string) (*Client, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, fmt.Errorf("SSH_AUTH_SOCK is not set, no SSH agent available")
+	}
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to SSH agent: %v", err)
+	}
+
+	client := newClient(host, port, user, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+	client.agentConn = conn
+	return client, nil
+}
+
+func newClient(host string, port int, user string, auth ssh.AuthMethod) *Client {
 	config := &ssh.ClientConfig{
 		User:            user,
 		Auth:            []ssh.AuthMethod{auth},
@@ -52,25 +138,136 @@ func NewClient(host string, port int, user string, authType string, authData str
 		Config: config,
 		Host:   host,
 		Port:   port,
-	}, nil
+	}
 }
 
 func (c *Client) Connect() error {
-	client, err := ssh.Dial("tcp", fmt.Sprintf("%s:%d", c.Host, c.Port), c.Config)
+	addr := net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+	conn, err := net.DialTimeout("tcp", addr, c.Timeout)
 	if err != nil {
 		return err
 	}
-	c.sshClient = client
+	return c.handshake(conn, addr)
+}
+
+// handshake sets up the SSH connection over conn. The connection is closed
+// if the server does not complete the handshake within the timeout.
+func (c *Client) handshake(conn net.Conn, addr string) error {
+	var timer *time.Timer
+	if c.Timeout > 0 {
+		timer = time.AfterFunc(c.Timeout, func() { conn.Close() })
+	}
+
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, c.Config)
+	if timer != nil && !timer.Stop() {
+		if err == nil {
+			sshConn.Close()
+		}
+		return fmt.Errorf("ssh handshake with %s timed out after %s", addr, c.Timeout)
+	}
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	c.sshClient = ssh.NewClient(sshConn, chans, reqs)
+	if c.KeepaliveInterval > 0 {
+		go keepalive(c.sshClient, c.KeepaliveInterval, &c.lost)
+	}
+	return nil
+}
+
+// PrepareJump makes jump, a client for a jump host on the way to c, check
+// host keys and time out the same way as c. It keeps its own authentication,
+// so the credentials of c are never offered to the jump host.
+func (c *Client) PrepareJump(jump *Client) {
+	jump.Config.HostKeyCallback = c.Config.HostKeyCallback
+	jump.Timeout = c.Timeout
+	jump.KeepaliveInterval = c.KeepaliveInterval
+}
+
+// ConnectThrough connects to the host through a connected jump host, which
+// is closed together with c.
+func (c *Client) ConnectThrough(jump *Client) error {
+	addr := net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+	ctx := context.Background()
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	conn, err := jump.sshClient.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to reach %s through %s: %v", addr, jump.Host, err)
+	}
+	if err := c.handshake(conn, addr); err != nil {
+		return err
+	}
+	c.jump = jump
 	return nil
 }
 
+// Run executes cmd in a new session on the remote host.
+func (c *Client) Run(cmd string, stdin io.Reader, stdout, stderr io.Writer) error {
+	session, err := c.sshClient.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to create session: %v", err)
+	}
+	return c.runSession(session, cmd, stdin, stdout, stderr)
+}
+
+// runSession runs cmd in an open session and closes it afterwards.
+func (c *Client) runSession(session *ssh.Session, cmd string, stdin io.Reader, stdout, stderr io.Writer) error {
+	defer session.Close()
+
+	session.Stdin = stdin
+	session.Stdout = stdout
+	session.Stderr = stderr
+
+	var err error
+	stalled := false
+	if c.StallTimeout == 0 || stdout == nil {
+		err = session.Run(cmd)
+	} else {
+		watched := newStallWriter(stdout)
+		session.Stdout = watched
+		if stderr != nil {
+			session.Stderr = activityWriter{w: stderr, stall: watched}
+		}
+		stop := watched.watch(c.StallTimeout, func() { session.Close() })
+		err = session.Run(cmd)
+		stalled = stop()
+	}
+
+	switch {
+	case stalled:
+		return fmt.Errorf("no output for %s, transfer stalled", c.StallTimeout)
+	case err != nil && c.lost.Load():
+		return fmt.Errorf("connection to %s lost, no reply to keepalives", c.Host)
+	}
+	return err
+}
+
+// Dial opens a direct-tcpip channel to addr as seen from the remote host.
+func (c *Client) Dial(network, addr string) (net.Conn, error) {
+	return c.sshClient.Dial(network, addr)
+}
+
 func (c *Client) GetSSHClient() *ssh.Client {
 	return c.sshClient
 }
 
 func (c *Client) Close() error {
+	if c.agentConn != nil {
+		c.agentConn.Close()
+	}
+	var err error
 	if c.sshClient != nil {
-		return c.sshClient.Close()
+		err = c.sshClient.Close()
 	}
-	return nil
+	if c.jump != nil {
+		c.jump.Close()
+	}
+	return err
 }
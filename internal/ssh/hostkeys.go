@@ -0,0 +1,87 @@
+package ssh
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// VerifyHostKeys replaces the default of accepting any host key. Host
+// certificates signed by a key in the hostCA file are accepted, other host
+// keys must be listed in one of the knownHosts files, which are separated by
+// spaces. Either may be empty.
+func (c *Client) VerifyHostKeys(knownHosts, hostCA string) error {
+	if knownHosts == "" && hostCA == "" {
+		return nil
+	}
+
+	fallback := func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		return fmt.Errorf("host key of %s is not signed by a trusted host CA", hostname)
+	}
+	if knownHosts != "" {
+		callback, err := knownhosts.New(strings.Fields(knownHosts)...)
+		if err != nil {
+			return fmt.Errorf("unable to load known hosts: %v", err)
+		}
+		if hostCA == "" {
+			c.Config.HostKeyCallback = callback
+			return nil
+		}
+		fallback = callback
+	}
+
+	authorities, err := loadAuthorities(hostCA)
+	if err != nil {
+		return err
+	}
+	checker := &ssh.CertChecker{
+		IsHostAuthority: func(auth ssh.PublicKey, address string) bool {
+			for _, ca := range authorities {
+				if bytes.Equal(ca.Marshal(), auth.Marshal()) {
+					return true
+				}
+			}
+			return false
+		},
+		HostKeyFallback: fallback,
+	}
+	c.Config.HostKeyCallback = checker.CheckHostKey
+	return nil
+}
+
+// loadAuthorities reads CA public keys in authorized_keys format, one per
+// line. Empty lines and lines starting with # are skipped.
+func loadAuthorities(path string) ([]ssh.PublicKey, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read host CA: %v", err)
+	}
+	defer file.Close()
+
+	var keys []ssh.PublicKey
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			return nil, fmt.Errorf("invalid host CA key in %s: %v", path, err)
+		}
+		keys = append(keys, key)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read host CA: %v", err)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no host CA keys found in %s", path)
+	}
+	return keys, nil
+}
@@ -0,0 +1,114 @@
+package ssh
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// keepaliveCountMax is the number of keepalive intervals without a reply
+// after which the connection is considered dead, like ServerAliveCountMax.
+const keepaliveCountMax = 3
+
+// keepalive sends keepalive@openssh.com requests every interval until the
+// connection is closed. If the server stops replying, lost is set and the
+// connection is closed, which fails every session still running on it.
+func keepalive(client *ssh.Client, interval time.Duration, lost *atomic.Bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		reply := make(chan error, 1)
+		go func() {
+			// Servers answer unknown global requests with a failure, which
+			// still proves the connection is alive.
+			_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+			reply <- err
+		}()
+
+		select {
+		case err := <-reply:
+			if err != nil {
+				return
+			}
+		case <-time.After(keepaliveCountMax * interval):
+			lost.Store(true)
+			client.Close()
+			return
+		}
+	}
+}
+
+// stallWriter records when output was last written to it.
+type stallWriter struct {
+	w    io.Writer
+	mu   sync.Mutex
+	last time.Time
+}
+
+func newStallWriter(w io.Writer) *stallWriter {
+	return &stallWriter{w: w, last: time.Now()}
+}
+
+func (s *stallWriter) Write(p []byte) (int, error) {
+	s.touch()
+	return s.w.Write(p)
+}
+
+func (s *stallWriter) touch() {
+	s.mu.Lock()
+	s.last = time.Now()
+	s.mu.Unlock()
+}
+
+// activityWriter passes writes to w and counts them as output of stall, so
+// progress on stderr keeps a command that has no output yet from stalling.
+type activityWriter struct {
+	w     io.Writer
+	stall *stallWriter
+}
+
+func (a activityWriter) Write(p []byte) (int, error) {
+	a.stall.touch()
+	return a.w.Write(p)
+}
+
+func (s *stallWriter) idle() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return time.Since(s.last)
+}
+
+// watch calls abort once nothing has been written for timeout. The returned
+// function stops watching and reports whether abort was called.
+func (s *stallWriter) watch(timeout time.Duration, abort func()) func() bool {
+	done := make(chan struct{})
+	stalled := make(chan bool, 1)
+
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				stalled <- false
+				return
+			case <-ticker.C:
+				if s.idle() >= timeout {
+					abort()
+					<-done
+					stalled <- true
+					return
+				}
+			}
+		}
+	}()
+
+	return func() bool {
+		close(done)
+		return <-stalled
+	}
+}
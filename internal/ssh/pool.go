@@ -0,0 +1,190 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// poolRetries is how often a command is moved to another connection when
+// the server refuses to open its session.
+const poolRetries = 3
+
+// Pool spreads sessions over several connections to the same server, so
+// running many commands at once stays within the server's MaxSessions.
+// Connections are opened one at a time and only when all others are full.
+type Pool struct {
+	open     func() (*Client, error)
+	sessions int
+
+	mu      sync.Mutex
+	opening sync.Mutex
+	conns   []*pooledConn
+}
+
+// pooledConn counts the sessions reserved on a connection and how many of
+// them are open. limit drops to the number of open sessions when the server
+// refuses one more.
+type pooledConn struct {
+	client   *Client
+	reserved int
+	open     int
+	limit    int
+	broken   bool
+}
+
+// NewPool opens the first connection with open and returns a pool running at
+// most sessions commands on each connection.
+func NewPool(sessions int, open func() (*Client, error)) (*Pool, error) {
+	client, err := open()
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Pool{open: open, sessions: sessions}
+	p.conns = append(p.conns, &pooledConn{client: client, limit: sessions})
+	return p, nil
+}
+
+// Run executes cmd on a connection with a free session. If the server
+// refuses to open the session, the command is retried on another connection.
+func (p *Pool) Run(cmd string, stdin io.Reader, stdout, stderr io.Writer) error {
+	var err error
+	for attempt := 0; attempt < poolRetries; attempt++ {
+		conn, acquireErr := p.acquire()
+		if acquireErr != nil {
+			return acquireErr
+		}
+
+		session, sessionErr := conn.client.sshClient.NewSession()
+		if sessionErr != nil {
+			p.refused(conn, sessionErr)
+			err = fmt.Errorf("failed to create session: %v", sessionErr)
+			continue
+		}
+
+		p.mu.Lock()
+		conn.open++
+		p.mu.Unlock()
+
+		err = conn.client.runSession(session, cmd, stdin, stdout, stderr)
+		p.release(conn, true)
+		return err
+	}
+	return err
+}
+
+// Dial opens a direct-tcpip channel on the least busy connection.
+func (p *Pool) Dial(network, addr string) (net.Conn, error) {
+	conn, err := p.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer p.release(conn, false)
+	return conn.client.Dial(network, addr)
+}
+
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var err error
+	for _, conn := range p.conns {
+		if closeErr := conn.client.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	p.conns = nil
+	return err
+}
+
+// acquire reserves a session on the least busy connection, opening a new
+// connection when every existing one is full.
+func (p *Pool) acquire() (*pooledConn, error) {
+	if conn := p.reserve(); conn != nil {
+		return conn, nil
+	}
+
+	// Only one connection is opened at a time, to stay clear of MaxStartups.
+	p.opening.Lock()
+	defer p.opening.Unlock()
+	if conn := p.reserve(); conn != nil {
+		return conn, nil
+	}
+
+	client, err := p.open()
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	conn := &pooledConn{client: client, reserved: 1, limit: p.sessions}
+	p.conns = append(p.conns, conn)
+	return conn, nil
+}
+
+func (p *Pool) reserve() *pooledConn {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var best *pooledConn
+	for _, conn := range p.conns {
+		if conn.broken || conn.reserved >= conn.limit {
+			continue
+		}
+		if best == nil || conn.reserved < best.reserved {
+			best = conn
+		}
+	}
+	if best != nil {
+		best.reserved++
+	}
+	return best
+}
+
+// refused handles a session the server would not open. If other sessions
+// are open, the connection is full at that number and so are new ones.
+// Otherwise the connection is unusable and is dropped.
+func (p *Pool) refused(conn *pooledConn, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var openErr *ssh.OpenChannelError
+	if errors.As(err, &openErr) && conn.open > 0 {
+		conn.limit = conn.open
+		if conn.open < p.sessions {
+			p.sessions = conn.open
+		}
+	} else {
+		conn.broken = true
+	}
+	p.releaseLocked(conn, false)
+}
+
+func (p *Pool) release(conn *pooledConn, opened bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.releaseLocked(conn, opened)
+}
+
+func (p *Pool) releaseLocked(conn *pooledConn, opened bool) {
+	conn.reserved--
+	if opened {
+		conn.open--
+	}
+
+	if conn.broken && conn.reserved == 0 {
+		conn.client.Close()
+		for i, c := range p.conns {
+			if c == conn {
+				p.conns = append(p.conns[:i], p.conns[i+1:]...)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// ManifestSuffix is appended to a backup file name to get its manifest.
+	ManifestSuffix = ".json"
+	partialSuffix  = ".partial"
+)
+
+// Manifest describes a stored backup file. It is written next to the backup
+// once the file is complete, so a backup without a manifest is incomplete.
+type Manifest struct {
+	Server    string    `json:"server"`
+	Database  string    `json:"database,omitempty"`
+	Engine    string    `json:"engine"`
+	Mode      string    `json:"mode"`
+	File      string    `json:"file"`
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+
+	// Physical backups record the InnoDB log sequence numbers they cover,
+	// and the last one copied while the backup was running.
+	BackupType string `json:"backup_type,omitempty"`
+	FromLSN    string `json:"from_lsn,omitempty"`
+	ToLSN      string `json:"to_lsn,omitempty"`
+	LastLSN    string `json:"last_lsn,omitempty"`
+
+	// Logical dumps taken with binlog archiving enabled record the binlog
+	// coordinates they are consistent with.
+	BinlogFile     string `json:"binlog_file,omitempty"`
+	BinlogPosition uint64 `json:"binlog_position,omitempty"`
+
+	// Archived binary logs record the time range of their events.
+	FirstEvent *time.Time `json:"first_event,omitempty"`
+	LastEvent  *time.Time `json:"last_event,omitempty"`
+}
+
+// File is a backup being streamed to disk. Data is written to a temporary
+// file and checksummed on the way; Commit moves it into place.
+type File struct {
+	path string
+	file *os.File
+	hash hash.Hash
+	size int64
+}
+
+func Create(path string) (*File, error) {
+	file, err := os.OpenFile(path+partialSuffix, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backup file: %v", err)
+	}
+
+	return &File{
+		path: path,
+		file: file,
+		hash: sha256.New(),
+	}, nil
+}
+
+func (f *File) Write(p []byte) (int, error) {
+	n, err := f.file.Write(p)
+	f.hash.Write(p[:n])
+	f.size += int64(n)
+	return n, err
+}
+
+// Commit finalizes the backup file and writes manifest next to it. The file
+// name, size and checksum fields of manifest are filled in.
+func (f *File) Commit(manifest *Manifest) error {
+	if err := f.file.Sync(); err != nil {
+		f.Abort()
+		return fmt.Errorf("failed to sync backup file: %v", err)
+	}
+	if err := f.file.Close(); err != nil {
+		f.Abort()
+		return fmt.Errorf("failed to close backup file: %v", err)
+	}
+	if err := os.Rename(f.path+partialSuffix, f.path); err != nil {
+		f.Abort()
+		return fmt.Errorf("failed to rename backup file: %v", err)
+	}
+
+	manifest.File = filepath.Base(f.path)
+	manifest.Size = f.size
+	manifest.SHA256 = hex.EncodeToString(f.hash.Sum(nil))
+
+	return WriteManifest(f.path, manifest)
+}
+
+// Abort discards the partially written file.
+func (f *File) Abort() error {
+	f.file.Close()
+	if err := os.Remove(f.path + partialSuffix); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func WriteManifest(backupPath string, manifest *Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %v", err)
+	}
+
+	if err := os.WriteFile(backupPath+ManifestSuffix, data, 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %v", err)
+	}
+	return nil
+}
+
+func ReadManifest(backupPath string) (*Manifest, error) {
+	data, err := os.ReadFile(backupPath + ManifestSuffix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %v", err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %v", err)
+	}
+	return &manifest, nil
+}
+
+// Verify recomputes the checksum of the backup at backupPath and compares it
+// with the one recorded in its manifest.
+func Verify(backupPath string, manifest *Manifest) error {
+	file, err := os.Open(backupPath)
+	if err != nil {
+		return fmt.Errorf("failed to open backup file: %v", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return fmt.Errorf("failed to read backup file: %v", err)
+	}
+
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != manifest.SHA256 {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", backupPath, manifest.SHA256, sum)
+	}
+	return nil
+}
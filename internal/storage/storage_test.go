@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(path string, manifest *Manifest) error
+		wantErr string
+	}{
+		{name: "intact"},
+		{
+			name:    "changed content",
+			modify:  func(path string, _ *Manifest) error { return os.WriteFile(path, []byte("backup datA"), 0644) },
+			wantErr: "checksum mismatch",
+		},
+		{
+			name:    "truncated",
+			modify:  func(path string, _ *Manifest) error { return os.Truncate(path, 3) },
+			wantErr: "checksum mismatch",
+		},
+		{
+			name:    "missing file",
+			modify:  func(path string, _ *Manifest) error { return os.Remove(path) },
+			wantErr: "failed to open backup file",
+		},
+		{
+			name:    "empty checksum",
+			modify:  func(_ string, manifest *Manifest) error { manifest.SHA256 = ""; return nil },
+			wantErr: "checksum mismatch",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "db.sql.gz")
+			file, err := Create(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := file.Write([]byte("backup data")); err != nil {
+				t.Fatal(err)
+			}
+			if err := file.Commit(&Manifest{Server: "prod", Engine: "mysql"}); err != nil {
+				t.Fatal(err)
+			}
+
+			manifest, err := ReadManifest(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if manifest.File != "db.sql.gz" || manifest.Size != int64(len("backup data")) {
+				t.Errorf("manifest records %s with %d bytes", manifest.File, manifest.Size)
+			}
+
+			if tt.modify != nil {
+				if err := tt.modify(path, manifest); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err = Verify(path, manifest)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Verify: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAbortRemovesPartialFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.sql.gz")
+	file, err := Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write([]byte("partial"))
+	if err := file.Abort(); err != nil {
+		t.Fatalf("Abort: %v", err)
+	}
+
+	for _, name := range []string{path, path + partialSuffix, path + ManifestSuffix} {
+		if _, err := os.Stat(name); !os.IsNotExist(err) {
+			t.Errorf("%s was left behind", filepath.Base(name))
+		}
+	}
+}